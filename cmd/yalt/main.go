@@ -4,20 +4,50 @@ import (
 	"flag"
 	"fmt"
 	"github.com/joakimcarlsson/yalt/internal/engine"
+	"github.com/joakimcarlsson/yalt/internal/env"
 	"log"
 	"os"
+	"strings"
 )
 
+// stringList is a flag that can be repeated to collect multiple values
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 func main() {
-	scriptFile := flag.String("script", "", "Path to the script file")
+	scriptFile := flag.String("script", "", "Path to the script file (.js or .ts)")
+	var outputs stringList
+	flag.Var(&outputs, "out", "Metrics output in the form name=argument, e.g. prometheus-rw=URL (repeatable)")
+	var reports stringList
+	flag.Var(&reports, "report", "Report written after the run in the form format=path, e.g. html=report.html or junit=junit.xml (repeatable)")
+	var envVars stringList
+	flag.Var(&envVars, "e", "Environment variable exposed to the script as __ENV in the form KEY=VALUE (repeatable)")
+	includeSystemEnv := flag.Bool("include-system-env-vars", false, "Expose the process environment variables to the script as __ENV")
 	flag.Parse()
 
 	if *scriptFile == "" {
-		fmt.Println("Usage: go run main.go -script=path/to/your/script.js")
+		fmt.Println("Usage: go run main.go -script=path/to/your/script.js|ts")
 		os.Exit(1)
 	}
 
-	runtime, err := engine.New(*scriptFile)
+	environment, err := env.Build(envVars, *includeSystemEnv)
+	if err != nil {
+		log.Fatalf("Error parsing environment variables: %v", err)
+	}
+
+	runtime, err := engine.New(*scriptFile, engine.Settings{
+		Outputs: outputs,
+		Reports: reports,
+		Env:     environment,
+	})
 	if err != nil {
 		log.Fatalf("Error creating engine: %v", err)
 	}
@@ -2,11 +2,27 @@ module github.com/joakimcarlsson/yalt
 
 go 1.22.5
 
-require github.com/dop251/goja v0.0.0-20240707163329-b1681fb2a2f5
+require (
+	github.com/PuerkitoBio/goquery v1.9.2
+	github.com/andybalholm/brotli v1.1.0
+	github.com/antchfx/xmlquery v1.4.1
+	github.com/dop251/goja v0.0.0-20240707163329-b1681fb2a2f5
+	github.com/evanw/esbuild v0.23.1
+	github.com/golang/snappy v0.0.4
+	github.com/tidwall/gjson v1.17.3
+	golang.org/x/net v0.24.0
+	google.golang.org/protobuf v1.34.2
+)
 
 require (
+	github.com/andybalholm/cascadia v1.3.2 // indirect
+	github.com/antchfx/xpath v1.3.1 // indirect
 	github.com/dlclark/regexp2 v1.7.0 // indirect
 	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
-	golang.org/x/text v0.3.8 // indirect
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
@@ -4,50 +4,64 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/env"
+	"github.com/joakimcarlsson/yalt/internal/http"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
 	"github.com/joakimcarlsson/yalt/internal/models"
+	"github.com/joakimcarlsson/yalt/internal/modules"
+	"github.com/joakimcarlsson/yalt/internal/output"
 	"log"
-	"os"
 	"time"
 )
 
-func LoadConfig(scriptPath string) (*models.Options, []byte, error) {
+// LoadConfig runs the script and extracts the options it exports.
+// The programs compiled by the loader are cached, so the virtual users reuse them.
+func LoadConfig(
+	scriptPath string,
+	environment map[string]string,
+	loader *modules.Loader,
+) (*models.Options, error) {
 	vm := goja.New()
 
-	exports := vm.NewObject()
-	_ = vm.Set("exports", exports)
-
-	script, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error reading script file: %w", err)
+	if err := env.Register(vm, environment); err != nil {
+		return nil, err
+	}
+	if err := modules.RegisterSharedArray(vm, loader); err != nil {
+		return nil, err
+	}
+	// Request bodies may be built at the top level of the script, so FormData is needed outside the VUs too
+	if err := http.RegisterFormData(vm); err != nil {
+		return nil, err
 	}
 
-	if _, err = vm.RunString(string(script)); err != nil {
-		return nil, nil, fmt.Errorf("error running script: %w", err)
+	exports, err := modules.NewRegistry(vm, loader).Require(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("error running script: %w", err)
 	}
 
-	optionsVal := exports.Get("options")
-	if goja.IsUndefined(optionsVal) {
+	optionsVal := exports.ToObject(vm).Get("options")
+	if optionsVal == nil || goja.IsUndefined(optionsVal) {
 		log.Println("options is undefined in the script")
-		return nil, nil, fmt.Errorf("options not found in script")
+		return nil, fmt.Errorf("options not found in script")
 	}
 
 	optionsJSON, err := json.Marshal(optionsVal)
 	if err != nil {
 		log.Println("failed to marshal options to JSON:", err)
-		return nil, nil, fmt.Errorf("error marshaling options: %w", err)
+		return nil, fmt.Errorf("error marshaling options: %w", err)
 	}
 
 	var options models.Options
 	if err := json.Unmarshal(optionsJSON, &options); err != nil {
 		log.Println("failed to unmarshal options JSON:", err)
-		return nil, nil, fmt.Errorf("error unmarshaling options: %w", err)
+		return nil, fmt.Errorf("error unmarshaling options: %w", err)
 	}
 
 	if err := validateOptions(&options); err != nil {
-		return nil, nil, fmt.Errorf("invalid options: %w", err)
+		return nil, fmt.Errorf("invalid options: %w", err)
 	}
 
-	return &options, script, nil
+	return &options, nil
 }
 
 func validateOptions(options *models.Options) error {
@@ -75,5 +89,24 @@ func validateOptions(options *models.Options) error {
 			}
 		}
 	}
-	return nil
+	if options.MinIterationDuration != "" {
+		if _, err := time.ParseDuration(options.MinIterationDuration); err != nil {
+			return fmt.Errorf("invalid min iteration duration: %w", err)
+		}
+	}
+	if options.ThinkTime != nil {
+		if _, err := options.ThinkTime.GetDurations(); err != nil {
+			return fmt.Errorf("invalid think time: %w", err)
+		}
+	}
+	if options.Batch < 0 || options.BatchPerHost < 0 {
+		return fmt.Errorf("batch limits cannot be negative")
+	}
+	if err := http.ValidateOptions(options); err != nil {
+		return err
+	}
+	if err := metrics.ValidateThresholds(options.Thresholds); err != nil {
+		return err
+	}
+	return output.ValidateOptions(&options.Outputs)
 }
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joakimcarlsson/yalt/internal/models"
+	"github.com/joakimcarlsson/yalt/internal/modules"
+)
+
+// validOptions returns the smallest options that pass validation
+func validOptions() *models.Options {
+	return &models.Options{
+		Stages: []models.Stage{{Duration: "10s", Target: 1}},
+	}
+}
+
+func TestValidateOptionsTLSAndHTTPVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		set   func(*models.Options)
+		valid bool
+	}{
+		{"versions", func(o *models.Options) {
+			o.TLS = &models.TLSOptions{MinVersion: "tls1.2", MaxVersion: "tls1.3"}
+		}, true},
+		{"cipher suite", func(o *models.Options) {
+			o.TLS = &models.TLSOptions{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}
+		}, true},
+		{"unknown min version", func(o *models.Options) { o.TLS = &models.TLSOptions{MinVersion: "tls2.0"} }, false},
+		{"unknown max version", func(o *models.Options) { o.TLS = &models.TLSOptions{MaxVersion: "ssl3"} }, false},
+		{"unknown cipher suite", func(o *models.Options) { o.TLS = &models.TLSOptions{CipherSuites: []string{"TLS_FAST"}} }, false},
+		{"invalid ca certs", func(o *models.Options) { o.TLS = &models.TLSOptions{CACerts: "not pem"} }, false},
+		{"h2c", func(o *models.Options) { o.HTTPVersion = models.HTTPVersionH2C }, true},
+		{"unknown http version", func(o *models.Options) { o.HTTPVersion = "3" }, false},
+	} {
+		options := validOptions()
+		tc.set(options)
+		if err := validateOptions(options); (err == nil) != tc.valid {
+			t.Errorf("%s: got error %v, want valid %v", tc.name, err, tc.valid)
+		}
+	}
+}
+
+func TestLoadConfigSupportsFormData(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.js")
+	if err := os.WriteFile(script, []byte(`
+		const upload = new FormData();
+		upload.append("file", new Uint8Array([1, 2, 3]).buffer, "data.bin");
+
+		export const options = { stages: [{ duration: "10s", target: 1 }] };
+	`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(script, nil, modules.NewLoader()); err != nil {
+		t.Fatal(err)
+	}
+}
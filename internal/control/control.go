@@ -0,0 +1,11 @@
+// Package control holds the errors scripts and modules use to stop a virtual user or abort the test.
+package control
+
+import "errors"
+
+var (
+	// ErrStopVU stops the virtual user that returned it for the rest of the test, across all stages
+	ErrStopVU = errors.New("virtual user stopped")
+	// ErrAbortTest aborts the whole test
+	ErrAbortTest = errors.New("test aborted")
+)
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// csvOptions holds the options of csv.parse
+type csvOptions struct {
+	// Header uses the first record as the field names of the following records, true by default
+	Header *bool `json:"header"`
+	// Delimiter separates the fields, a comma by default
+	Delimiter string `json:"delimiter"`
+}
+
+// parseCSV parses CSV text into an array of objects, or an array of arrays when options.header is false
+func parseCSV(
+	vm *goja.Runtime,
+	parse goja.Callable,
+	text string,
+	optionsVal goja.Value,
+) goja.Value {
+	var options csvOptions
+	if err := exportOptions(optionsVal, &options); err != nil {
+		panic(vm.NewTypeError("invalid csv options: %v", err))
+	}
+
+	rows, err := csvRows(text, options)
+	if err != nil {
+		panic(vm.NewGoError(err))
+	}
+
+	values := make([]interface{}, len(rows))
+	for i, row := range rows {
+		value, err := parse(goja.Undefined(), vm.ToValue(row))
+		if err != nil {
+			panic(err)
+		}
+		values[i] = value
+	}
+	return vm.NewArray(values...)
+}
+
+// csvRows parses CSV text into rows encoded as JSON, keeping the column order of objects
+func csvRows(
+	text string,
+	options csvOptions,
+) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	if options.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(options.Delimiter)
+		if size != len(options.Delimiter) {
+			return nil, fmt.Errorf("csv delimiter must be a single character")
+		}
+		reader.Comma = delimiter
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing csv: %w", err)
+	}
+
+	header := options.Header == nil || *options.Header
+	if !header {
+		rows := make([]string, len(records))
+		for i, record := range records {
+			encoded, err := json.Marshal(record)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding csv record: %w", err)
+			}
+			rows[i] = string(encoded)
+		}
+		return rows, nil
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+	rows := make([]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		rows = append(rows, encodeRecord(records[0], record))
+	}
+	return rows, nil
+}
+
+// encodeRecord encodes a record as a JSON object keyed by the header, in column order
+func encodeRecord(header, record []string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range header {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		b.Write(key)
+		b.WriteByte(':')
+		value := ""
+		if i < len(record) {
+			value = record[i]
+		}
+		encoded, _ := json.Marshal(value)
+		b.Write(encoded)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// exportOptions decodes an optional options object into target
+func exportOptions(
+	value goja.Value,
+	target interface{},
+) error {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, target)
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/modules"
+)
+
+// ModuleName is the name scripts use to require or import the data module
+const ModuleName = "yalt/data"
+
+// Module holds the state of the data module shared by every runtime, so feeders are coordinated across VUs
+type Module struct {
+	loader  *modules.Loader
+	mu      sync.Mutex
+	sources map[string]*source
+}
+
+// New creates a new data Module reading files through the loader
+func New(loader *modules.Loader) *Module {
+	return &Module{
+		loader:  loader,
+		sources: make(map[string]*source),
+	}
+}
+
+// Register makes the data module available to scripts as ModuleName
+func (m *Module) Register() {
+	m.loader.RegisterBuiltin(ModuleName, m.exports)
+}
+
+// exports creates the exports of the data module for a runtime
+func (m *Module) exports(
+	vm *goja.Runtime,
+	dir string,
+) (goja.Value, error) {
+	parse, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	if !ok {
+		return nil, fmt.Errorf("JSON.parse is not a function")
+	}
+
+	csv := vm.NewObject()
+	if err := csv.Set("parse", func(call goja.FunctionCall) goja.Value {
+		return parseCSV(vm, parse, call.Argument(0).String(), call.Argument(1))
+	}); err != nil {
+		return nil, fmt.Errorf("failed to set csv.parse: %w", err)
+	}
+
+	exports := vm.NewObject()
+	if err := exports.Set("csv", csv); err != nil {
+		return nil, fmt.Errorf("failed to set csv: %w", err)
+	}
+	if err := exports.Set("feeder", func(call goja.FunctionCall) goja.Value {
+		return m.newFeeder(vm, parse, dir, call.Argument(0).String(), call.Argument(1))
+	}); err != nil {
+		return nil, fmt.Errorf("failed to set feeder: %w", err)
+	}
+	return exports, nil
+}
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/control"
+)
+
+const (
+	// StrategyUnique hands out every row once across all VUs
+	StrategyUnique = "unique"
+	// StrategySequential walks every row in order, separately for each VU
+	StrategySequential = "sequential"
+	// StrategyRandom picks a random row on every call and is never exhausted
+	StrategyRandom = "random"
+	// StrategyPerVU always returns the row matching the VU's ID
+	StrategyPerVU = "perVU"
+)
+
+const (
+	// ExhaustedStop stops the VU once the rows are exhausted
+	ExhaustedStop = "stop"
+	// ExhaustedRecycle starts over from the first row
+	ExhaustedRecycle = "recycle"
+	// ExhaustedAbort aborts the whole test
+	ExhaustedAbort = "abort"
+)
+
+// feederOptions holds the options of feeder
+type feederOptions struct {
+	csvOptions
+	Strategy    string `json:"strategy"`
+	OnExhausted string `json:"onExhausted"`
+}
+
+// source holds the rows of a data file, loaded once, and the position shared by the VUs feeding from it
+type source struct {
+	once sync.Once
+	rows []string
+	err  error
+	next int64
+}
+
+// feeder hands out the rows of a source to a single runtime
+type feeder struct {
+	vm      *goja.Runtime
+	parse   goja.Callable
+	path    string
+	source  *source
+	options feederOptions
+	// cursor is the position of this VU for StrategySequential
+	cursor int
+}
+
+// newFeeder creates a feeder over the CSV or JSON file at path, relative to dir
+func (m *Module) newFeeder(
+	vm *goja.Runtime,
+	parse goja.Callable,
+	dir string,
+	path string,
+	optionsVal goja.Value,
+) goja.Value {
+	options := feederOptions{Strategy: StrategySequential, OnExhausted: ExhaustedStop}
+	if err := exportOptions(optionsVal, &options); err != nil {
+		panic(vm.NewTypeError("invalid feeder options: %v", err))
+	}
+	switch options.Strategy {
+	case StrategyUnique, StrategySequential, StrategyRandom, StrategyPerVU:
+	default:
+		panic(vm.NewTypeError("invalid feeder strategy %q", options.Strategy))
+	}
+	switch options.OnExhausted {
+	case ExhaustedStop, ExhaustedRecycle, ExhaustedAbort:
+	default:
+		panic(vm.NewTypeError("invalid feeder onExhausted %q", options.OnExhausted))
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	src := m.source(sourceKey(path, options))
+	src.once.Do(func() {
+		src.rows, src.err = m.loadRows(path, options.csvOptions)
+	})
+	if src.err != nil {
+		panic(vm.NewGoError(src.err))
+	}
+
+	f := &feeder{vm: vm, parse: parse, path: path, source: src, options: options}
+	obj := vm.NewObject()
+	if err := obj.Set("next", f.next); err != nil {
+		panic(vm.NewGoError(err))
+	}
+	if err := obj.Set("length", len(src.rows)); err != nil {
+		panic(vm.NewGoError(err))
+	}
+	return obj
+}
+
+// source returns the source registered under key, creating it if there is none
+func (m *Module) source(key string) *source {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	src, ok := m.sources[key]
+	if !ok {
+		src = &source{}
+		m.sources[key] = src
+	}
+	return src
+}
+
+// sourceKey returns the key of the source a feeder reads from.
+// Feeders only share rows and position when they parse the file and hand out its rows the same way.
+func sourceKey(
+	path string,
+	options feederOptions,
+) string {
+	header := options.Header == nil || *options.Header
+	return fmt.Sprintf("%s|%s|%s|%t|%q", path, options.Strategy, options.OnExhausted, header, options.Delimiter)
+}
+
+// loadRows reads the rows of a JSON array file, or of a CSV file for any other extension
+func (m *Module) loadRows(
+	path string,
+	options csvOptions,
+) ([]string, error) {
+	content, err := m.loader.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []string
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(content, &elements); err != nil {
+			return nil, fmt.Errorf("error parsing %s, expected a JSON array: %w", path, err)
+		}
+		for _, element := range elements {
+			rows = append(rows, string(element))
+		}
+	} else if rows, err = csvRows(string(content), options); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("data file %s has no rows", path)
+	}
+	return rows, nil
+}
+
+// next returns the next row according to the strategy
+func (f *feeder) next(goja.FunctionCall) goja.Value {
+	index, err := f.index()
+	if err != nil {
+		panic(f.vm.NewGoError(err))
+	}
+	row, err := f.parse(goja.Undefined(), f.vm.ToValue(f.source.rows[index]))
+	if err != nil {
+		panic(err)
+	}
+	return row
+}
+
+// index returns the index of the next row, applying options.onExhausted when the rows run out
+func (f *feeder) index() (int, error) {
+	count := len(f.source.rows)
+
+	var position int
+	switch f.options.Strategy {
+	case StrategyRandom:
+		return rand.Intn(count), nil
+	case StrategyUnique:
+		position = int(atomic.AddInt64(&f.source.next, 1) - 1)
+	case StrategySequential:
+		position = f.cursor
+		f.cursor++
+	case StrategyPerVU:
+		// __VU is only set in VU runtimes, not while the options are read
+		position = max(int(f.vm.Get("__VU").ToInteger())-1, 0)
+	}
+
+	if position < count {
+		return position, nil
+	}
+	switch f.options.OnExhausted {
+	case ExhaustedRecycle:
+		return position % count, nil
+	case ExhaustedAbort:
+		return 0, fmt.Errorf("feeder %s is exhausted: %w", f.path, control.ErrAbortTest)
+	default:
+		return 0, fmt.Errorf("feeder %s is exhausted: %w", f.path, control.ErrStopVU)
+	}
+}
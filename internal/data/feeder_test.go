@@ -0,0 +1,71 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/modules"
+)
+
+func TestFeedersShareSourcesOnlyWithMatchingOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "users.csv"), []byte("name;id\nada;1\ngrace;2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "main.js")
+	if err := os.WriteFile(script, []byte(`
+		const { feeder } = require("yalt/data");
+		const semicolon = { strategy: "unique", delimiter: ";" };
+		const first = feeder("./users.csv", semicolon);
+		const second = feeder("./users.csv", semicolon);
+		exports.shared = [first.next().name, second.next().name];
+
+		const comma = feeder("./users.csv", { strategy: "unique" });
+		exports.comma = Object.keys(comma.next());
+
+		const noHeader = feeder("./users.csv", { strategy: "unique", delimiter: ";", header: false });
+		exports.noHeader = [noHeader.length, noHeader.next()];
+
+		const recycled = feeder("./users.csv", { strategy: "unique", delimiter: ";", onExhausted: "recycle" });
+		exports.recycled = recycled.next().name;
+	`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := modules.NewLoader()
+	New(loader).Register()
+	vm := goja.New()
+	exports, err := modules.NewRegistry(vm, loader).Require(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := func(name string) string {
+		value, err := vm.RunString("JSON.stringify")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stringify, _ := goja.AssertFunction(value)
+		result, err := stringify(goja.Undefined(), exports.ToObject(vm).Get(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.String()
+	}
+	for name, want := range map[string]string{
+		// Feeders with the same options hand out the rows of one source in turn
+		"shared": `["ada","grace"]`,
+		// A different delimiter parses the file into different rows
+		"comma": `["name;id"]`,
+		// Without a header the first record is a row of its own
+		"noHeader": `[3,["name","id"]]`,
+		// A different onExhausted starts at the first row rather than sharing the exhausted position
+		"recycled": `"ada"`,
+	} {
+		if got := got(name); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+}
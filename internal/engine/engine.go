@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -11,60 +12,190 @@ import (
 	"time"
 
 	"github.com/joakimcarlsson/yalt/internal/config"
+	"github.com/joakimcarlsson/yalt/internal/control"
+	"github.com/joakimcarlsson/yalt/internal/data"
 	"github.com/joakimcarlsson/yalt/internal/http"
 	"github.com/joakimcarlsson/yalt/internal/metrics"
 	"github.com/joakimcarlsson/yalt/internal/models"
+	"github.com/joakimcarlsson/yalt/internal/modules"
+	"github.com/joakimcarlsson/yalt/internal/output"
+	"github.com/joakimcarlsson/yalt/internal/report"
 	"github.com/joakimcarlsson/yalt/internal/virtualuser"
 )
 
-const progressBarLength = 30
+const (
+	progressBarLength = 30
+	// idlePollInterval is how often an inactive virtual user checks whether it has been ramped up
+	idlePollInterval = 100 * time.Millisecond
+)
 
 type Engine struct {
-	pool        *virtualuser.UserPool
-	options     *models.Options
-	metrics     *metrics.Metrics
-	activeUsers int64
-	taskChan    chan struct{}
+	pool               *virtualuser.UserPool
+	options            *models.Options
+	thinkTimeDurations models.ThinkTimeDurations
+	metrics            *metrics.Metrics
+	outputs            []output.Output
+	reports            []report.Report
+	activeUsers        int64
+	stageIndex         int64
+	startTime          time.Time
+	// minIterationDuration is the shortest time a single iteration may take
+	minIterationDuration time.Duration
+	// cancel stops the whole test, abortOnce and abortErr record why a script aborted it
+	cancel    context.CancelFunc
+	abortOnce sync.Once
+	abortErr  error
+}
+
+// Settings holds the command line settings for a test run
+type Settings struct {
+	// Outputs lists the --out specifications, e.g. otlp=http://localhost:4318
+	Outputs []string
+	// Reports lists the --report specifications, e.g. html=report.html
+	Reports []string
+	// Env holds the variables exposed to the script as __ENV
+	Env map[string]string
 }
 
 // Run starts the engine
 func (e *Engine) Run() error {
+	// Outputs are stopped however the run ends, so their final flush is not lost
+	var started []output.Output
+	defer func() {
+		for _, out := range started {
+			if err := out.Stop(); err != nil {
+				log.Printf("Error stopping output: %v", err)
+			}
+		}
+	}()
+	for _, out := range e.outputs {
+		if err := out.Start(); err != nil {
+			return fmt.Errorf("error starting output: %w", err)
+		}
+		started = append(started, out)
+	}
+
+	e.startTime = time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	go e.sampleVUs(ctx)
+
 	for i, stage := range e.options.Stages {
-		if err := e.runStage(stage, i+1); err != nil {
+		atomic.StoreInt64(&e.stageIndex, int64(i))
+		if err := e.runStage(ctx, stage, i+1); err != nil {
+			cancel()
 			return fmt.Errorf("error running stage: %w", err)
 		}
+		if ctx.Err() != nil {
+			break
+		}
 		log.Println("Stage completed")
 	}
-	e.metrics.CalculateAndDisplayMetrics()
+	cancel()
+
+	summary := e.metrics.CalculateAndDisplayMetrics()
+	for _, r := range e.reports {
+		if err := r.Write(summary, e.options); err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+	}
+
+	if e.abortErr != nil {
+		return e.abortErr
+	}
 	return nil
 }
 
 // New creates a new Engine instance
-func New(scriptPath string) (*Engine, error) {
-	options, scriptContent, err := config.LoadConfig(scriptPath)
+func New(
+	scriptPath string,
+	settings Settings,
+) (*Engine, error) {
+	loader := modules.NewLoader()
+	data.New(loader).Register()
+	options, err := config.LoadConfig(scriptPath, settings.Env, loader)
 	if err != nil {
 		return nil, fmt.Errorf("error extracting options: %w", err)
 	}
 
+	var thinkTimeDurations models.ThinkTimeDurations
+	if options.ThinkTime != nil {
+		if thinkTimeDurations, err = options.ThinkTime.GetDurations(); err != nil {
+			return nil, fmt.Errorf("error parsing think time: %w", err)
+		}
+	}
+
+	var minIterationDuration time.Duration
+	if options.MinIterationDuration != "" {
+		if minIterationDuration, err = time.ParseDuration(options.MinIterationDuration); err != nil {
+			return nil, fmt.Errorf("error parsing min iteration duration: %w", err)
+		}
+	}
+
 	maxVuCount := getMaxVuCount(options)
 	httpMetrics := metrics.NewMetrics(options.Thresholds)
-	client := http.NewClient(httpMetrics)
+	client, err := http.NewClient(httpMetrics, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
 
-	pool, err := virtualuser.CreatePool(maxVuCount, scriptContent, client)
+	outputs := make([]output.Output, 0, len(settings.Outputs))
+	for _, spec := range settings.Outputs {
+		out, err := output.New(spec, options, httpMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("error creating output: %w", err)
+		}
+		if propagator, ok := out.(output.TracePropagator); ok && propagator.PropagatesTraces() {
+			client.EnableTracePropagation()
+		}
+		outputs = append(outputs, out)
+	}
+
+	reports := make([]report.Report, 0, len(settings.Reports))
+	for _, spec := range settings.Reports {
+		r, err := report.New(spec)
+		if err != nil {
+			return nil, fmt.Errorf("error creating report: %w", err)
+		}
+		reports = append(reports, r)
+	}
+
+	e := &Engine{
+		options:              options,
+		thinkTimeDurations:   thinkTimeDurations,
+		metrics:              httpMetrics,
+		outputs:              outputs,
+		reports:              reports,
+		minIterationDuration: minIterationDuration,
+	}
+
+	e.pool, err = virtualuser.CreatePool(maxVuCount, virtualuser.Config{
+		Client:              client,
+		Metrics:             httpMetrics,
+		State:               e,
+		Env:                 settings.Env,
+		Loader:              loader,
+		ScriptPath:          scriptPath,
+		NoCookiesReset:      options.NoCookiesReset,
+		NoVUConnectionReuse: options.NoVUConnectionReuse,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating user pool: %w", err)
 	}
 
-	return &Engine{
-		pool:     pool,
-		options:  options,
-		metrics:  httpMetrics,
-		taskChan: make(chan struct{}, maxVuCount),
-	}, nil
+	initialVuCount := options.Stages[0].Target
+	if err := e.pool.Preallocate(initialVuCount, func(initialized int) {
+		displayInitProgress(initialized, initialVuCount)
+	}); err != nil {
+		return nil, fmt.Errorf("error initializing virtual users: %w", err)
+	}
+
+	return e, nil
 }
 
 // runStage runs a stage with a given target number of virtual users
 func (e *Engine) runStage(
+	parent context.Context,
 	stage models.Stage,
 	stageNumber int,
 ) error {
@@ -75,7 +206,7 @@ func (e *Engine) runStage(
 		return fmt.Errorf("error getting durations: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	ctx, cancel := context.WithTimeout(parent, duration)
 	defer cancel()
 
 	startUsers := int(atomic.LoadInt64(&e.activeUsers))
@@ -89,60 +220,97 @@ func (e *Engine) runStage(
 		e.rampUsers(ctx, startUsers, endUsers, rampUp, rampDown, duration)
 	}()
 
-	for i := 0; i < endUsers; i++ {
+	for i := 0; i < max(startUsers, endUsers); i++ {
 		wg.Add(1)
-		go e.runVirtualUser(ctx, &wg)
+		go e.runVirtualUser(ctx, &wg, i)
 	}
 
 	go e.displayStageProgress(ctx, stage, stageNumber)
 
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			close(e.taskChan)
-			wg.Wait()
-			return nil
-		case <-ticker.C:
-			activeUsers := int(atomic.LoadInt64(&e.activeUsers))
-			e.sendTasks(activeUsers)
-		}
-	}
+	wg.Wait()
+	return nil
 }
 
-// runVirtualUser runs a virtual user
+// runVirtualUser runs iterations back to back while the virtual user's index is within the active user count
 func (e *Engine) runVirtualUser(
 	ctx context.Context,
 	wg *sync.WaitGroup,
+	index int,
 ) {
 	defer wg.Done()
-	user := e.pool.Fetch()
+	user, err := e.pool.Fetch()
+	if err != nil {
+		e.abort(fmt.Errorf("error initializing virtual user: %w", err))
+		return
+	}
 	defer e.pool.Return(user)
+	if user.Stopped() {
+		return
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
+	for ctx.Err() == nil {
+		if int64(index) >= atomic.LoadInt64(&e.activeUsers) {
+			e.wait(ctx, idlePollInterval)
+			continue
+		}
+		if err := e.runIteration(ctx, user); errors.Is(err, control.ErrStopVU) {
+			log.Printf("Virtual user %d stopped: %v", user.ID(), err)
 			return
-		case _, ok := <-e.taskChan:
-			if !ok {
-				return
-			}
-			if err := user.Run(ctx); err != nil {
-				log.Printf("Error running virtual user: %v", err)
-			}
 		}
+		e.think(ctx)
 	}
 }
 
-// sendTasks sends tasks to virtual users
-func (e *Engine) sendTasks(activeUsers int) {
-	for i := 0; i < activeUsers; i++ {
-		select {
-		case e.taskChan <- struct{}{}:
-		default:
-		}
+// runIteration runs a single iteration, records its metrics and pads it to options.minIterationDuration.
+// It aborts the test when the script asks to, and returns the error of the iteration.
+func (e *Engine) runIteration(
+	ctx context.Context,
+	user *virtualuser.VirtualUser,
+) error {
+	start := time.Now()
+	err := user.Run(ctx)
+	elapsed := time.Since(start)
+
+	switch {
+	case errors.Is(err, control.ErrAbortTest):
+		e.abort(err)
+		return err
+	case errors.Is(err, control.ErrStopVU):
+		return err
+	case err != nil:
+		log.Printf("Error running virtual user: %v", err)
+	}
+
+	if ctx.Err() == nil {
+		e.metrics.AddIteration(elapsed)
+	}
+
+	if elapsed < e.minIterationDuration {
+		e.wait(ctx, e.minIterationDuration-elapsed)
+	}
+	return err
+}
+
+// abort stops the test, keeping the first reason given
+func (e *Engine) abort(err error) {
+	e.abortOnce.Do(func() {
+		log.Printf("Aborting test: %v", err)
+		e.abortErr = err
+		e.cancel()
+	})
+}
+
+// wait pauses for the given duration, returning early when the context is cancelled
+func (e *Engine) wait(
+	ctx context.Context,
+	duration time.Duration,
+) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
 	}
 }
 
@@ -238,6 +406,55 @@ func (e *Engine) displayStageProgress(
 	}
 }
 
+// sampleVUs records the number of active virtual users every second until the context is cancelled
+func (e *Engine) sampleVUs(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	e.metrics.RecordVUs(atomic.LoadInt64(&e.activeUsers))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.metrics.RecordVUs(atomic.LoadInt64(&e.activeUsers))
+		}
+	}
+}
+
+// displayInitProgress displays the progress of the virtual user initialization
+func displayInitProgress(initialized, total int) {
+	bar := initialized * progressBarLength / total
+	fmt.Printf(
+		"\rInitializing VUs [%s%s] %d / %d",
+		strings.Repeat("=", bar),
+		strings.Repeat("-", progressBarLength-bar),
+		initialized,
+		total,
+	)
+	if initialized == total {
+		fmt.Println()
+	}
+}
+
+// StageIndex returns the 0-based index of the stage currently running
+func (e *Engine) StageIndex() int {
+	return int(atomic.LoadInt64(&e.stageIndex))
+}
+
+// ActiveVUs returns the number of virtual users currently active
+func (e *Engine) ActiveVUs() int64 {
+	return atomic.LoadInt64(&e.activeUsers)
+}
+
+// Elapsed returns the time since the test started
+func (e *Engine) Elapsed() time.Duration {
+	if e.startTime.IsZero() {
+		return 0
+	}
+	return time.Since(e.startTime)
+}
+
 // getMaxVuCount calculates the maximum number of virtual users
 func getMaxVuCount(options *models.Options) int {
 	maxVuCount := 0
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// thinkTime returns the pause to apply after an iteration according to options.thinkTime
+func (e *Engine) thinkTime() time.Duration {
+	if e.options.ThinkTime == nil {
+		return 0
+	}
+
+	d := e.thinkTimeDurations
+	switch e.options.ThinkTime.Type {
+	case models.ThinkTimeFixed:
+		return d.Duration
+	case models.ThinkTimeUniform:
+		return d.Min + time.Duration(rand.Int63n(int64(d.Max-d.Min)+1))
+	case models.ThinkTimeGaussian:
+		pause := time.Duration(float64(d.Mean) + rand.NormFloat64()*float64(d.StdDev))
+		if pause < 0 {
+			return 0
+		}
+		return pause
+	}
+	return 0
+}
+
+// think pauses a virtual user between iterations, returning early when the context is cancelled
+func (e *Engine) think(ctx context.Context) {
+	if pause := e.thinkTime(); pause > 0 {
+		e.wait(ctx, pause)
+	}
+}
@@ -0,0 +1,53 @@
+// Package env builds the variables exposed to scripts as __ENV
+package env
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Build merges the process environment, when included, with the variables passed on the command line.
+// Command line variables take precedence.
+func Build(
+	vars []string,
+	includeSystemEnv bool,
+) (map[string]string, error) {
+	env := make(map[string]string)
+	if includeSystemEnv {
+		for _, kv := range os.Environ() {
+			if key, value, ok := strings.Cut(kv, "="); ok {
+				env[key] = value
+			}
+		}
+	}
+
+	for _, kv := range vars {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", kv)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// Register exposes the environment variables to the runtime as the __ENV object
+func Register(
+	vm *goja.Runtime,
+	env map[string]string,
+) error {
+	envObj := vm.NewObject()
+	for key, value := range env {
+		if err := envObj.Set(key, value); err != nil {
+			return fmt.Errorf("error setting environment variable %s: %w", key, err)
+		}
+	}
+
+	if err := vm.Set("__ENV", envObj); err != nil {
+		return fmt.Errorf("error setting __ENV object: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// setAuth adds the Authorization header for basic and bearer auth.
+// Digest auth needs the challenge of a first response, see digestAuthorization.
+func setAuth(
+	req *http.Request,
+	auth *authOptions,
+) {
+	switch auth.Type {
+	case authBasic:
+		req.SetBasicAuth(auth.Username, auth.Password)
+	case authBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	}
+}
+
+// digestChallenge returns the parameters of the Digest challenge in a 401 response, or nil if there is none
+func digestChallenge(resp *http.Response) map[string]string {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil
+	}
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		if len(header) > 7 && strings.EqualFold(header[:7], "Digest ") {
+			return parseAuthParams(header[7:])
+		}
+	}
+	return nil
+}
+
+// parseAuthParams parses comma separated key=value pairs whose values may be quoted
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			value = strings.ReplaceAll(s[1:min(end, len(s))], `\`, "")
+			s = s[min(end+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// digestAuthorization computes the Authorization header answering a Digest challenge (RFC 7616)
+func digestAuthorization(
+	req *http.Request,
+	auth *authOptions,
+	challenge map[string]string,
+) (string, error) {
+	algorithm := challenge["algorithm"]
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	digest := func(s string) string {
+		h := newHash()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 8)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", fmt.Errorf("error generating cnonce: %w", err)
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+	realm, nonce := challenge["realm"], challenge["nonce"]
+	uri := req.URL.RequestURI()
+
+	ha1 := digest(auth.Username + ":" + realm + ":" + auth.Password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = digest(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := digest(req.Method + ":" + uri)
+
+	qop := ""
+	for _, offered := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(offered) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop != "" {
+		response = digest(strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = digest(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		auth.Username, realm, nonce, uri, response)
+	if algorithm != "" {
+		header += ", algorithm=" + algorithm
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		header += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	if qop != "" {
+		header += fmt.Sprintf(", qop=%s, nc=%s, cnonce=\"%s\"", qop, nc, cnonce)
+	}
+	return header, nil
+}
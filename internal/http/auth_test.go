@@ -0,0 +1,76 @@
+package http
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthParams(t *testing.T) {
+	got := parseAuthParams(`realm="api, v2", qop="auth,auth-int", nonce=abc123, opaque="q\"uoted"`)
+	want := map[string]string{
+		"realm":  "api, v2",
+		"qop":    "auth,auth-int",
+		"nonce":  "abc123",
+		"opaque": `q"uoted`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("params = %v, want %v", got, want)
+	}
+}
+
+func TestSetAuth(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	setAuth(req, &authOptions{Type: authBasic, Username: "user", Password: "pass"})
+	if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	setAuth(req, &authOptions{Type: authBearer, Token: "t0k"})
+	if got := req.Header.Get("Authorization"); got != "Bearer t0k" {
+		t.Errorf("bearer auth = %q", got)
+	}
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{}}
+	resp.Header.Add("WWW-Authenticate", `Basic realm="x"`)
+	resp.Header.Add("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="n0nce", opaque="op"`)
+	challenge := digestChallenge(resp)
+	if challenge == nil {
+		t.Fatal("digest challenge not found")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/private?id=1", nil)
+	header, err := digestAuthorization(req, &authOptions{Username: "user", Password: "pass"}, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(header, "Digest ") {
+		t.Fatalf("header = %q", header)
+	}
+	params := parseAuthParams(strings.TrimPrefix(header, "Digest "))
+
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := md5Hex("user:test:pass")
+	ha2 := md5Hex("GET:/private?id=1")
+	want := md5Hex(strings.Join([]string{ha1, "n0nce", params["nc"], params["cnonce"], "auth", ha2}, ":"))
+	if params["response"] != want || params["uri"] != "/private?id=1" || params["opaque"] != "op" {
+		t.Errorf("unexpected authorization %q", header)
+	}
+
+	if _, err := digestAuthorization(req, &authOptions{}, map[string]string{"algorithm": "SHA-512"}); err == nil {
+		t.Error("unsupported algorithm was accepted")
+	}
+	if digestChallenge(&http.Response{StatusCode: http.StatusOK, Header: resp.Header}) != nil {
+		t.Error("challenge found in a successful response")
+	}
+}
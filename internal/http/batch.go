@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// Batch performs the requests in parallel and returns their responses in the same order.
+// At most options.batch requests run at once, and at most options.batchPerHost against the same host.
+func (c *Client) Batch(
+	ctx context.Context,
+	configs []map[string]interface{},
+) []*Response {
+	responses := make([]*Response, len(configs))
+	slots := make(chan struct{}, c.batch)
+	hostSlots := make(map[string]chan struct{})
+	for _, config := range configs {
+		host := batchHost(config)
+		if _, ok := hostSlots[host]; !ok {
+			hostSlots[host] = make(chan struct{}, c.batchPerHost)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, config := range configs {
+		wg.Add(1)
+		go func(i int, config map[string]interface{}) {
+			defer wg.Done()
+
+			// Take the host slot first, so requests waiting on a busy host do not hold up other hosts
+			hostSlot := hostSlots[batchHost(config)]
+			hostSlot <- struct{}{}
+			defer func() { <-hostSlot }()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			responses[i] = c.do(ctx, config)
+		}(i, config)
+	}
+	wg.Wait()
+
+	return responses
+}
+
+// batchHost returns the host a request config targets, or an empty string when its URL is invalid
+func batchHost(config map[string]interface{}) string {
+	rawURL, _ := config["url"].(string)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// batchConfigs converts the argument of batch into request configs, accepting URL strings as GET requests
+func batchConfigs(
+	vm *goja.Runtime,
+	value goja.Value,
+) ([]map[string]interface{}, error) {
+	elements, ok := value.Export().([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid argument type: expected an array of request configs")
+	}
+
+	configs := make([]map[string]interface{}, len(elements))
+	for i, element := range elements {
+		switch element := element.(type) {
+		case string:
+			configs[i] = map[string]interface{}{"url": element}
+		case map[string]interface{}:
+			configs[i] = element
+		default:
+			return nil, fmt.Errorf("invalid request at index %d: expected a request config object or URL", i)
+		}
+		if err := prepareBody(configs[i]); err != nil {
+			return nil, fmt.Errorf("invalid request at index %d: %w", i, err)
+		}
+	}
+	return configs, nil
+}
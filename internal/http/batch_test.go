@@ -0,0 +1,192 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// concurrencyServer is a test server that records how many requests it handles at once
+type concurrencyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+// newConcurrencyServer starts a server that holds every request for a while before answering
+func newConcurrencyServer(t *testing.T) *concurrencyServer {
+	t.Helper()
+	s := &concurrencyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.current++
+		s.max = max(s.max, s.current)
+		s.mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		s.mu.Lock()
+		s.current--
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// maxConcurrency returns the most requests the server handled at once
+func (s *concurrencyServer) maxConcurrency() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.max
+}
+
+// batchClient returns the client of a virtual user with the given batch limits
+func batchClient(
+	t *testing.T,
+	batch int,
+	batchPerHost int,
+) *Client {
+	t.Helper()
+	template, err := NewClient(metrics.NewMetrics(nil), &models.Options{Batch: batch, BatchPerHost: batchPerHost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := template.ForVirtualUser(NewCookieJar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// urlConfigs returns n GET request configs for the URL
+func urlConfigs(
+	url string,
+	n int,
+) []map[string]interface{} {
+	configs := make([]map[string]interface{}, n)
+	for i := range configs {
+		configs[i] = map[string]interface{}{"url": url}
+	}
+	return configs
+}
+
+func TestBatchKeepsRequestOrder(t *testing.T) {
+	// Earlier requests answer later, so completion order is the reverse of request order
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var delay int
+		fmt.Sscan(r.URL.Query().Get("delay"), &delay)
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+		fmt.Fprint(w, r.URL.Query().Get("i"))
+	}))
+	defer server.Close()
+
+	configs := make([]map[string]interface{}, 5)
+	for i := range configs {
+		configs[i] = map[string]interface{}{"url": fmt.Sprintf("%s/?i=%d&delay=%d", server.URL, i, (len(configs)-i)*20)}
+	}
+
+	responses := testClient(t).Batch(context.Background(), configs)
+	if len(responses) != len(configs) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(configs))
+	}
+	for i, response := range responses {
+		if string(response.Body) != fmt.Sprint(i) {
+			t.Errorf("response %d has body %q", i, response.Body)
+		}
+	}
+}
+
+func TestBatchLimitsConcurrency(t *testing.T) {
+	server := newConcurrencyServer(t)
+
+	responses := batchClient(t, 3, 10).Batch(context.Background(), urlConfigs(server.URL, 10))
+	for i, response := range responses {
+		if response.Status != http.StatusOK {
+			t.Errorf("response %d: status %d, error %q", i, response.Status, response.Error)
+		}
+	}
+	if got := server.maxConcurrency(); got != 3 {
+		t.Errorf("max concurrency = %d, want 3", got)
+	}
+}
+
+func TestBatchLimitsConcurrencyPerHost(t *testing.T) {
+	first := newConcurrencyServer(t)
+	second := newConcurrencyServer(t)
+
+	configs := append(urlConfigs(first.URL, 6), urlConfigs(second.URL, 6)...)
+	responses := batchClient(t, 10, 2).Batch(context.Background(), configs)
+	for i, response := range responses {
+		if response.Status != http.StatusOK {
+			t.Errorf("response %d: status %d, error %q", i, response.Status, response.Error)
+		}
+	}
+	for name, server := range map[string]*concurrencyServer{"first": first, "second": second} {
+		if got := server.maxConcurrency(); got != 2 {
+			t.Errorf("%s host max concurrency = %d, want 2", name, got)
+		}
+	}
+}
+
+func TestBatchReportsErrorsPerRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	configs := []map[string]interface{}{
+		{"url": server.URL},
+		{"url": "://invalid"},
+		{"url": server.URL},
+	}
+	responses := testClient(t).Batch(context.Background(), configs)
+	for i, response := range responses {
+		failed := i == 1
+		if (response.Error != "") != failed {
+			t.Errorf("response %d: status %d, error %q", i, response.Status, response.Error)
+		}
+	}
+	if responses[1].ErrorCode == 0 {
+		t.Error("failed request has no error code")
+	}
+}
+
+func TestBatchConfigsRejectsInvalidElements(t *testing.T) {
+	vm := goja.New()
+	for _, tc := range []struct {
+		script string
+		err    string
+	}{
+		{`"https://example.com/"`, "invalid argument type: expected an array of request configs"},
+		{`["https://example.com/", 42]`, "invalid request at index 1: expected a request config object or URL"},
+		{`[{url: "https://example.com/"}, {url: "https://example.com/", body: 42}]`, "invalid request at index 1: "},
+	} {
+		value, err := vm.RunString(tc.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := batchConfigs(vm, value); err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+			t.Errorf("%s: error = %v, want %q", tc.script, err, tc.err)
+		}
+	}
+
+	value, err := vm.RunString(`["https://example.com/a", {url: "https://example.com/b", method: "POST", body: "data"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, err := batchConfigs(vm, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs[0]["url"] != "https://example.com/a" || configs[1]["method"] != "POST" {
+		t.Errorf("configs = %v", configs)
+	}
+}
@@ -0,0 +1,217 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// requestBody is a request body encoded ahead of sending, with the Content-Type it implies
+type requestBody struct {
+	data        []byte
+	contentType string
+}
+
+// prepareBody encodes the body of a request config in place. It runs on the goroutine owning the runtime,
+// so buffers and form data the script changes after starting the request do not affect it.
+func prepareBody(config map[string]interface{}) error {
+	body, err := encodeBody(config["body"], headerValue(config, "Content-Type"))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		config["body"] = body
+	}
+	return nil
+}
+
+// encodeBody encodes a body given as a string, ArrayBuffer, Uint8Array, FormData or plain object.
+// Objects are URL-encoded, or encoded as JSON when the Content-Type header asks for it.
+func encodeBody(
+	body interface{},
+	contentType string,
+) (*requestBody, error) {
+	switch body := body.(type) {
+	case nil:
+		return nil, nil
+	case *requestBody:
+		return body, nil
+	case string:
+		return &requestBody{data: []byte(body)}, nil
+	case goja.ArrayBuffer:
+		return &requestBody{data: bytes.Clone(body.Bytes()), contentType: "application/octet-stream"}, nil
+	case []byte:
+		return &requestBody{data: bytes.Clone(body), contentType: "application/octet-stream"}, nil
+	case *FormData:
+		return body.encode()
+	case map[string]interface{}:
+		if strings.Contains(strings.ToLower(contentType), "json") {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return nil, fmt.Errorf("%w: error encoding JSON body: %v", errInvalidRequest, err)
+			}
+			return &requestBody{data: data}, nil
+		}
+		return &requestBody{data: []byte(urlEncode(body)), contentType: "application/x-www-form-urlencoded"}, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported body type %T", errInvalidRequest, body)
+}
+
+// urlEncode encodes an object as a URL-encoded form, repeating the key for every element of an array value
+func urlEncode(fields map[string]interface{}) string {
+	values := url.Values{}
+	for key, value := range fields {
+		if elements, ok := value.([]interface{}); ok {
+			for _, element := range elements {
+				values.Add(key, fmt.Sprint(element))
+			}
+			continue
+		}
+		values.Add(key, fmt.Sprint(value))
+	}
+	return values.Encode()
+}
+
+// headerValue returns a header of a request config, matching the name case-insensitively
+func headerValue(
+	config map[string]interface{},
+	name string,
+) string {
+	headers, _ := config["headers"].(map[string]interface{})
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if strings.EqualFold(key, name) {
+			value, _ := headers[key].(string)
+			return value
+		}
+	}
+	return ""
+}
+
+// formPart is a field or file of a FormData
+type formPart struct {
+	name        string
+	value       []byte
+	filename    string
+	contentType string
+	file        bool
+}
+
+// FormData builds a multipart/form-data body from fields and files
+type FormData struct {
+	vm    *goja.Runtime
+	parts []formPart
+}
+
+// RegisterFormData registers the FormData constructor in the runtime.
+// append(name, value, filename?, contentType?) adds a field, or a file part when the value is binary or a filename is given.
+func RegisterFormData(vm *goja.Runtime) error {
+	constructor := func(goja.ConstructorCall) *goja.Object {
+		return vm.NewDynamicObject(&FormData{vm: vm})
+	}
+	if err := vm.Set("FormData", constructor); err != nil {
+		return fmt.Errorf("error setting FormData: %w", err)
+	}
+	return nil
+}
+
+// append adds a part to the form
+func (f *FormData) append(call goja.FunctionCall) goja.Value {
+	part := formPart{name: call.Argument(0).String()}
+
+	switch value := call.Argument(1).Export().(type) {
+	case goja.ArrayBuffer:
+		part.value = bytes.Clone(value.Bytes())
+		part.file = true
+	case []byte:
+		part.value = bytes.Clone(value)
+		part.file = true
+	default:
+		part.value = []byte(call.Argument(1).String())
+	}
+
+	if filename := call.Argument(2); !goja.IsUndefined(filename) {
+		part.filename = filename.String()
+		part.file = true
+	}
+	if part.file && part.filename == "" {
+		part.filename = part.name
+	}
+	part.contentType = "application/octet-stream"
+	if contentType := call.Argument(3); !goja.IsUndefined(contentType) {
+		part.contentType = contentType.String()
+	}
+
+	f.parts = append(f.parts, part)
+	return goja.Undefined()
+}
+
+// encode writes the parts as a multipart/form-data body
+func (f *FormData) encode() (*requestBody, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, part := range f.parts {
+		header := textproto.MIMEHeader{}
+		if part.file {
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(part.name), escapeQuotes(part.filename)))
+			header.Set("Content-Type", part.contentType)
+		} else {
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(part.name)))
+		}
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("error creating multipart part: %w", err)
+		}
+		if _, err := w.Write(part.value); err != nil {
+			return nil, fmt.Errorf("error writing multipart part: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error closing multipart body: %w", err)
+	}
+	return &requestBody{data: buf.Bytes(), contentType: writer.FormDataContentType()}, nil
+}
+
+// escapeQuotes escapes a value for a quoted Content-Disposition parameter
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
+
+// Get implements goja.DynamicObject, exposing the append method
+func (f *FormData) Get(key string) goja.Value {
+	if key == "append" {
+		return f.vm.ToValue(f.append)
+	}
+	return nil
+}
+
+// Set implements goja.DynamicObject, FormData has no writable properties
+func (f *FormData) Set(string, goja.Value) bool {
+	return false
+}
+
+// Has implements goja.DynamicObject
+func (f *FormData) Has(key string) bool {
+	return key == "append"
+}
+
+// Delete implements goja.DynamicObject, FormData has no deletable properties
+func (f *FormData) Delete(string) bool {
+	return false
+}
+
+// Keys implements goja.DynamicObject
+func (f *FormData) Keys() []string {
+	return []string{"append"}
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestEncodeBody(t *testing.T) {
+	vm := goja.New()
+	buffer, err := vm.RunString(`new Uint8Array([1, 2, 3]).buffer`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		body        interface{}
+		header      string
+		data        string
+		contentType string
+	}{
+		{"string", "raw", "", "raw", ""},
+		{"array buffer", buffer.Export(), "", "\x01\x02\x03", "application/octet-stream"},
+		{"bytes", []byte("bin"), "", "bin", "application/octet-stream"},
+		{"form object", map[string]interface{}{"b": "x y", "a": []interface{}{int64(1), int64(2)}}, "", "a=1&a=2&b=x+y", "application/x-www-form-urlencoded"},
+		{"json object", map[string]interface{}{"id": int64(7)}, "application/json; charset=utf-8", `{"id":7}`, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := encodeBody(tc.body, tc.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body.data) != tc.data || body.contentType != tc.contentType {
+				t.Errorf("got %q with %q, want %q with %q", body.data, body.contentType, tc.data, tc.contentType)
+			}
+		})
+	}
+
+	if body, err := encodeBody(nil, ""); body != nil || err != nil {
+		t.Errorf("nil body = %v, %v", body, err)
+	}
+	if _, err := encodeBody(int64(1), ""); !errors.Is(err, errInvalidRequest) {
+		t.Errorf("number body error = %v, want errInvalidRequest", err)
+	}
+}
+
+func TestPrepareBodyMatchesContentTypeCaseInsensitively(t *testing.T) {
+	config := map[string]interface{}{
+		"headers": map[string]interface{}{"content-type": "application/json"},
+		"body":    map[string]interface{}{"ok": true},
+	}
+	if err := prepareBody(config); err != nil {
+		t.Fatal(err)
+	}
+	if body := config["body"].(*requestBody); string(body.data) != `{"ok":true}` {
+		t.Errorf("body = %s", body.data)
+	}
+}
+
+func TestFormDataEncodesMultipart(t *testing.T) {
+	vm := goja.New()
+	if err := RegisterFormData(vm); err != nil {
+		t.Fatal(err)
+	}
+	form, err := vm.RunString(`
+		const form = new FormData();
+		form.append("title", "Report \"Q1\"");
+		form.append("data", new Uint8Array([104, 105]).buffer);
+		form.append("notes", "plain text", "notes.txt", "text/plain");
+		form;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := encodeBody(form.Export(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(body.contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("content type %q: %v", body.contentType, err)
+	}
+
+	type part struct{ name, filename, contentType, value string }
+	var parts []part
+	reader := multipart.NewReader(bytes.NewReader(body.data), params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, _ := io.ReadAll(p)
+		parts = append(parts, part{p.FormName(), p.FileName(), p.Header.Get("Content-Type"), string(value)})
+	}
+
+	want := []part{
+		{"title", "", "", `Report "Q1"`},
+		{"data", "data", "application/octet-stream", "hi"},
+		{"notes", "notes.txt", "text/plain", "plain text"},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(parts), len(want))
+	}
+	for i := range want {
+		if parts[i] != want[i] {
+			t.Errorf("part %d = %+v, want %+v", i, parts[i], want[i])
+		}
+	}
+}
+
+func TestFetchSendsFormDataWithFilePart(t *testing.T) {
+	type part struct{ name, filename, contentType, value string }
+	var parts []part
+	var contentLength, received int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		body, _ := io.ReadAll(r.Body)
+		received = int64(len(body))
+
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			p, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			value, _ := io.ReadAll(p)
+			parts = append(parts, part{p.FormName(), p.FileName(), p.Header.Get("Content-Type"), string(value)})
+		}
+	}))
+	defer server.Close()
+
+	vm := goja.New()
+	if err := RegisterFormData(vm); err != nil {
+		t.Fatal(err)
+	}
+	form, err := vm.RunString(`
+		const form = new FormData();
+		form.append("user", "ada");
+		form.append("avatar", new Uint8Array([137, 80, 78, 71]).buffer, "avatar.png", "image/png");
+		form;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := testClient(t).Fetch(context.Background(), map[string]interface{}{
+		"method": http.MethodPost,
+		"url":    server.URL,
+		"body":   form.Export(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.Status, resp.Body)
+	}
+
+	if contentLength <= 0 || contentLength != received {
+		t.Errorf("Content-Length %d, received %d bytes", contentLength, received)
+	}
+	want := []part{
+		{"user", "", "", "ada"},
+		{"avatar", "avatar.png", "image/png", "\x89PNG"},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(parts), len(want))
+	}
+	for i := range want {
+		if parts[i] != want[i] {
+			t.Errorf("part %d = %+v, want %+v", i, parts[i], want[i])
+		}
+	}
+}
@@ -1,72 +1,228 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/dop251/goja"
 	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
 	"log"
-	"net"
 	"net/http"
 	"time"
 )
 
-// Client wraps an HTTP client with custom settings
+const (
+	// defaultBatch is how many requests of a batch run in parallel unless options.batch says otherwise
+	defaultBatch = 20
+	// defaultBatchPerHost is how many requests of a batch run in parallel against one host unless options.batchPerHost says otherwise
+	defaultBatchPerHost = 6
+	// defaultMaxRedirects is how many redirects a request follows unless its redirects option says otherwise
+	defaultMaxRedirects = 10
+)
+
+// Client wraps an HTTP client with custom settings.
+// The Client returned by NewClient only holds the settings, ForVirtualUser gives each virtual user one that sends requests.
 type Client struct {
-	client *http.Client
+	// client is the HTTP client of a virtual user, nil in the Client returned by NewClient
+	client  *http.Client
+	metrics *metrics.Metrics
+	options *models.Options
+	// resolver is shared by the transports of all virtual users, nil without hosts or DNS options
+	resolver         *resolver
+	tracePropagation bool
+	batch            int
+	batchPerHost     int
+	// noConnectionReuse closes the connection after every request
+	noConnectionReuse bool
 }
 
-// NewClient initializes and returns a new Client with custom transport settings
-func NewClient(metrics *metrics.Metrics) *Client {
-	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		MaxIdleConns:          1000,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		MaxIdleConnsPerHost:   1000,
-		MaxConnsPerHost:       100,
+// NewClient initializes and returns the settings shared by the clients of the virtual users
+func NewClient(
+	metrics *metrics.Metrics,
+	options *models.Options,
+) (*Client, error) {
+	if err := ValidateOptions(options); err != nil {
+		return nil, err
+	}
+	resolver, err := newResolver(options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating resolver: %w", err)
 	}
 
-	client := &http.Client{
+	c := &Client{
+		metrics:           metrics,
+		options:           options,
+		resolver:          resolver,
+		batch:             defaultBatch,
+		batchPerHost:      defaultBatchPerHost,
+		noConnectionReuse: options.NoConnectionReuse,
+	}
+	if options.Batch > 0 {
+		c.batch = options.Batch
+	}
+	if options.BatchPerHost > 0 {
+		c.batchPerHost = options.BatchPerHost
+	}
+	return c, nil
+}
+
+// newHTTPClient creates an HTTP client recording the metrics of its requests over a new transport
+func newHTTPClient(
+	metrics *metrics.Metrics,
+	options *models.Options,
+	resolver *resolver,
+) (*http.Client, error) {
+	transport, err := newTransport(options, resolver)
+	if err != nil {
+		return nil, fmt.Errorf("error creating transport: %w", err)
+	}
+
+	return &http.Client{
 		Transport: metrics.NewMetricsRoundTripper(transport, metrics),
 		Timeout:   30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Same limit as the default policy of net/http
+			if len(via) >= defaultMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", defaultMaxRedirects)
+			}
+			return renewTraceparent(req)
+		},
+	}, nil
+}
+
+// ForVirtualUser returns a copy of the Client with its own HTTP client and transport, storing cookies in jar.
+// Like a real client, every virtual user keeps its own pool of connections.
+func (c *Client) ForVirtualUser(jar *CookieJar) (*Client, error) {
+	client, err := newHTTPClient(c.metrics, c.options, c.resolver)
+	if err != nil {
+		return nil, err
 	}
-	return &Client{client: client}
+	client.Jar = jar
+
+	vuClient := *c
+	vuClient.client = client
+	return &vuClient, nil
+}
+
+// CloseIdleConnections closes the connections kept alive by the transport of the Client
+func (c *Client) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
+
+// EventLoop schedules callbacks on the goroutine that owns a Goja runtime
+type EventLoop interface {
+	// Context returns the context of the iteration currently running on the loop
+	Context() context.Context
+	// RegisterCallback reserves a callback on the loop and returns the function used to schedule it
+	RegisterCallback() func(func() error)
 }
 
-// RegisterClientMethods registers the fetch method of the Client in the Goja runtime
+// RegisterClientMethods registers the fetch, batch and cookieJar methods of the Client in the Goja runtime.
+// The client is expected to come from ForVirtualUser with the same jar, which the cookieJar methods expose.
 func RegisterClientMethods(
 	vm *goja.Runtime,
 	client *Client,
+	loop EventLoop,
+	jar *CookieJar,
 ) error {
+	jarObj, err := newCookieJarObject(vm, jar)
+	if err != nil {
+		return err
+	}
+
 	clientObj := vm.NewObject()
 	if err := clientObj.Set("fetch", func(call goja.FunctionCall) goja.Value {
+		promise, resolve, reject := vm.NewPromise()
+
 		config, ok := call.Argument(0).Export().(map[string]interface{})
 		if !ok {
-			log.Println("Invalid argument type, expected map[string]interface{}")
-			return vm.ToValue("Invalid argument type")
+			reject(vm.NewTypeError("Invalid argument type, expected a request config object"))
+			return vm.ToValue(promise)
+		}
+		if err := prepareBody(config); err != nil {
+			reject(vm.NewTypeError(err.Error()))
+			return vm.ToValue(promise)
 		}
 
-		responseDetails, err := client.Fetch(config)
-		if err != nil {
-			log.Println("Error performing request:", err)
-			return vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
+		ctx := loop.Context()
+		callback := loop.RegisterCallback()
+		go func() {
+			response := client.do(ctx, config)
+			callback(func() error {
+				obj, err := response.toObject(vm)
+				if err != nil {
+					return err
+				}
+				resolve(obj)
+				return nil
 			})
-		}
+		}()
 
-		return vm.ToValue(responseDetails)
+		return vm.ToValue(promise)
 	}); err != nil {
 		return fmt.Errorf("error setting fetch method: %w", err)
 	}
 
+	if err := clientObj.Set("batch", func(call goja.FunctionCall) goja.Value {
+		promise, resolve, reject := vm.NewPromise()
+
+		configs, err := batchConfigs(vm, call.Argument(0))
+		if err != nil {
+			reject(vm.NewTypeError(err.Error()))
+			return vm.ToValue(promise)
+		}
+
+		ctx := loop.Context()
+		callback := loop.RegisterCallback()
+		go func() {
+			responses := client.Batch(ctx, configs)
+			callback(func() error {
+				objects := make([]interface{}, len(responses))
+				for i, response := range responses {
+					obj, err := response.toObject(vm)
+					if err != nil {
+						return err
+					}
+					objects[i] = obj
+				}
+				resolve(vm.NewArray(objects...))
+				return nil
+			})
+		}()
+
+		return vm.ToValue(promise)
+	}); err != nil {
+		return fmt.Errorf("error setting batch method: %w", err)
+	}
+
+	if err := clientObj.Set("cookieJar", func(goja.FunctionCall) goja.Value {
+		return jarObj
+	}); err != nil {
+		return fmt.Errorf("error setting cookieJar method: %w", err)
+	}
+
+	if err := RegisterFormData(vm); err != nil {
+		return err
+	}
+
 	if err := vm.Set("client", clientObj); err != nil {
 		return fmt.Errorf("error setting client object: %w", err)
 	}
 
 	return nil
 }
+
+// do performs a request, turning a failure into a Response describing it
+func (c *Client) do(
+	ctx context.Context,
+	config map[string]interface{},
+) *Response {
+	response, err := c.Fetch(ctx, config)
+	if err != nil {
+		log.Println("Error performing request:", err)
+		if response == nil {
+			response = &Response{Headers: http.Header{}, Error: err.Error(), ErrorCode: errorCode(err)}
+		}
+	}
+	return response
+}
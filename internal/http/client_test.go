@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+func TestVirtualUsersHaveTheirOwnConnections(t *testing.T) {
+	var connections int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	template, err := NewClient(metrics.NewMetrics(nil), &models.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.client != nil {
+		t.Error("NewClient built an HTTP client no virtual user sends with")
+	}
+
+	get := func(c *Client) {
+		t.Helper()
+		resp, err := c.client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	first, err := template.ForVirtualUser(NewCookieJar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := template.ForVirtualUser(NewCookieJar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(first)
+	get(first)
+	get(second)
+	if got := atomic.LoadInt64(&connections); got != 2 {
+		t.Errorf("got %d connections, want one per virtual user", got)
+	}
+
+	first.CloseIdleConnections()
+	get(first)
+	if got := atomic.LoadInt64(&connections); got != 3 {
+		t.Errorf("got %d connections after closing idle ones, want 3", got)
+	}
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is sent with every request that does not set Accept-Encoding itself
+const acceptEncoding = "gzip, deflate, br"
+
+// compress compresses a request body with the gzip, deflate or br algorithm
+func compress(
+	data []byte,
+	algorithm string,
+) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch algorithm {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("invalid compression %q, expected gzip, deflate or br", algorithm)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("error compressing body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decodes a response body according to its Content-Encoding header, undoing the encodings in reverse order.
+// Decoding stops at an encoding it does not know, returning the body as far as it could be decoded.
+func decompress(
+	data []byte,
+	contentEncoding string,
+) ([]byte, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var reader io.Reader
+		switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("error decompressing gzip body: %w", err)
+			}
+			reader = gz
+		case "deflate":
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("error decompressing deflate body: %w", err)
+			}
+			reader = zr
+		case "br":
+			reader = brotli.NewReader(bytes.NewReader(data))
+		default:
+			return data, nil
+		}
+
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing body: %w", err)
+		}
+		data = decoded
+	}
+	return data, nil
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("yalt "), 100)
+	for _, algorithm := range []string{"gzip", "deflate", "br"} {
+		compressed, err := compress(data, algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(compressed) >= len(data) {
+			t.Errorf("%s did not compress the body", algorithm)
+		}
+		decoded, err := decompress(compressed, algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("%s round trip changed the body", algorithm)
+		}
+	}
+
+	if _, err := compress(data, "zstd"); err == nil {
+		t.Error("unknown compression was accepted")
+	}
+}
+
+func TestDecompressLayeredEncodings(t *testing.T) {
+	gzipped, err := compress([]byte("body"), "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layered, err := compress(gzipped, "br")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decompress(layered, "gzip, BR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != "body" {
+		t.Errorf("decoded %q, want body", decoded)
+	}
+}
+
+func TestDecompressPassesUnknownEncodingsThrough(t *testing.T) {
+	for _, encoding := range []string{"", "identity", "zstd", "gzip, zstd"} {
+		decoded, err := decompress([]byte("opaque"), encoding)
+		if err != nil {
+			t.Fatalf("%q: %v", encoding, err)
+		}
+		if string(decoded) != "opaque" {
+			t.Errorf("%q decoded to %q", encoding, decoded)
+		}
+	}
+
+	if _, err := decompress([]byte("not gzip"), "gzip"); err == nil {
+		t.Error("corrupt gzip body was accepted")
+	}
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar is the cookie jar of a single virtual user, which can be reset between iterations
+type CookieJar struct {
+	mu  sync.Mutex
+	jar *cookiejar.Jar
+}
+
+// NewCookieJar creates a new, empty CookieJar
+func NewCookieJar() *CookieJar {
+	j := &CookieJar{}
+	j.Reset()
+	return j
+}
+
+// Reset removes every cookie from the jar
+func (j *CookieJar) Reset() {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	j.mu.Lock()
+	j.jar = jar
+	j.mu.Unlock()
+}
+
+// SetCookies implements http.CookieJar
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.current().SetCookies(u, cookies)
+}
+
+// Cookies implements http.CookieJar
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.current().Cookies(u)
+}
+
+// current returns the jar holding the cookies since the last reset
+func (j *CookieJar) current() *cookiejar.Jar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar
+}
+
+// cookieOptions holds the attributes of a cookie set by a script
+type cookieOptions struct {
+	Domain   string
+	Path     string
+	Expires  time.Time
+	MaxAge   int
+	Secure   bool
+	HTTPOnly bool
+}
+
+// parseCookieOptions reads the domain, path, expires, maxAge, secure and httpOnly attributes of a cookie
+func parseCookieOptions(config map[string]interface{}) (cookieOptions, error) {
+	var options cookieOptions
+	options.Domain, _ = config["domain"].(string)
+	options.Path, _ = config["path"].(string)
+	options.Secure, _ = config["secure"].(bool)
+	options.HTTPOnly, _ = config["httpOnly"].(bool)
+
+	switch maxAge := config["maxAge"].(type) {
+	case nil:
+	case int64:
+		options.MaxAge = int(maxAge)
+	case float64:
+		options.MaxAge = int(maxAge)
+	default:
+		return cookieOptions{}, fmt.Errorf("invalid maxAge, expected a number of seconds")
+	}
+
+	switch expires := config["expires"].(type) {
+	case nil:
+	case string:
+		t, err := time.Parse(time.RFC3339, expires)
+		if err != nil {
+			return cookieOptions{}, fmt.Errorf("invalid expires %q, expected RFC 3339", expires)
+		}
+		options.Expires = t
+	default:
+		return cookieOptions{}, fmt.Errorf("invalid expires, expected an RFC 3339 string")
+	}
+	return options, nil
+}
+
+// newCookieJarObject exposes the jar to scripts
+func newCookieJarObject(
+	vm *goja.Runtime,
+	jar *CookieJar,
+) (*goja.Object, error) {
+	parseURL := func(value goja.Value) *url.URL {
+		u, err := url.Parse(value.String())
+		if err != nil || u.Host == "" {
+			panic(vm.NewTypeError("invalid cookie URL %q", value.String()))
+		}
+		return u
+	}
+
+	obj := vm.NewObject()
+	for _, method := range []struct {
+		name string
+		fn   func(goja.FunctionCall) goja.Value
+	}{
+		// cookiesForURL returns the cookies sent to the URL as an object of names and values
+		{"cookiesForURL", func(call goja.FunctionCall) goja.Value {
+			cookies := vm.NewObject()
+			for _, cookie := range jar.Cookies(parseURL(call.Argument(0))) {
+				if err := cookies.Set(cookie.Name, cookie.Value); err != nil {
+					panic(vm.NewGoError(err))
+				}
+			}
+			return cookies
+		}},
+		// get returns the value of the cookie sent to the URL, or undefined when there is none
+		{"get", func(call goja.FunctionCall) goja.Value {
+			name := call.Argument(1).String()
+			for _, cookie := range jar.Cookies(parseURL(call.Argument(0))) {
+				if cookie.Name == name {
+					return vm.ToValue(cookie.Value)
+				}
+			}
+			return goja.Undefined()
+		}},
+		// set stores a cookie as if the URL had returned it, with optional attributes
+		{"set", func(call goja.FunctionCall) goja.Value {
+			var options cookieOptions
+			if arg := call.Argument(3); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+				config, ok := arg.Export().(map[string]interface{})
+				if !ok {
+					panic(vm.NewTypeError("invalid cookie options, expected an object"))
+				}
+				parsed, err := parseCookieOptions(config)
+				if err != nil {
+					panic(vm.NewTypeError("invalid cookie options: %v", err))
+				}
+				options = parsed
+			}
+			cookie := &http.Cookie{
+				Name:     call.Argument(1).String(),
+				Value:    call.Argument(2).String(),
+				Domain:   options.Domain,
+				Path:     options.Path,
+				Expires:  options.Expires,
+				MaxAge:   options.MaxAge,
+				Secure:   options.Secure,
+				HttpOnly: options.HTTPOnly,
+			}
+			jar.SetCookies(parseURL(call.Argument(0)), []*http.Cookie{cookie})
+			return goja.Undefined()
+		}},
+		// delete removes the named cookie sent to the URL
+		{"delete", func(call goja.FunctionCall) goja.Value {
+			u := parseURL(call.Argument(0))
+			jar.remove(u, call.Argument(1).String())
+			return goja.Undefined()
+		}},
+		// clear removes every cookie sent to the URL, or every cookie when no URL is given
+		{"clear", func(call goja.FunctionCall) goja.Value {
+			if goja.IsUndefined(call.Argument(0)) {
+				jar.Reset()
+				return goja.Undefined()
+			}
+			u := parseURL(call.Argument(0))
+			for _, cookie := range jar.Cookies(u) {
+				jar.remove(u, cookie.Name)
+			}
+			return goja.Undefined()
+		}},
+	} {
+		if err := obj.Set(method.name, method.fn); err != nil {
+			return nil, fmt.Errorf("error setting cookie jar %s: %w", method.name, err)
+		}
+	}
+	return obj, nil
+}
+
+// remove expires the named cookie sent to the URL. The jar does not expose where a cookie was stored,
+// so it is expired for every path leading to the URL, as a host-only cookie and for every parent domain.
+func (j *CookieJar) remove(
+	u *url.URL,
+	name string,
+) {
+	paths := []string{"/"}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := range segments {
+		if segments[i] != "" {
+			paths = append(paths, "/"+strings.Join(segments[:i+1], "/"))
+		}
+	}
+
+	domains := []string{""}
+	labels := strings.Split(u.Hostname(), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		domains = append(domains, strings.Join(labels[i:], "."))
+	}
+
+	var expired []*http.Cookie
+	for _, path := range paths {
+		for _, domain := range domains {
+			expired = append(expired, &http.Cookie{Name: name, Path: path, Domain: domain, MaxAge: -1})
+		}
+	}
+	j.SetCookies(u, expired)
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// cookieRuntime returns a runtime with a new, empty cookie jar bound to jar
+func cookieRuntime(t *testing.T) *goja.Runtime {
+	t.Helper()
+	vm := goja.New()
+	obj, err := newCookieJarObject(vm, NewCookieJar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Set("jar", obj); err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestCookieSetOptions(t *testing.T) {
+	vm := cookieRuntime(t)
+	if _, err := vm.RunString(`
+		jar.set("http://app.example.com/api/v1/items", "scoped", "1", { path: "/api" });
+		jar.set("http://app.example.com/", "shared", "2", { domain: "example.com" });
+		jar.set("https://app.example.com/", "secure", "3", { secure: true, httpOnly: true });
+		jar.set("http://app.example.com/", "expired", "4", { expires: "2000-01-01T00:00:00Z" });
+		jar.set("http://app.example.com/", "maxAge", "5", { maxAge: 60 });
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		url, name string
+		want      interface{}
+	}{
+		{"http://app.example.com/api/v2/items", "scoped", "1"},
+		{"http://app.example.com/other", "scoped", nil},
+		{"http://cdn.example.com/", "shared", "2"},
+		{"https://app.example.com/", "secure", "3"},
+		{"http://app.example.com/", "secure", nil},
+		{"http://app.example.com/", "expired", nil},
+		{"http://app.example.com/", "maxAge", "5"},
+	} {
+		value, err := vm.RunString(`jar.get("` + tc.url + `", "` + tc.name + `")`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := value.Export(); got != tc.want {
+			t.Errorf("get(%s, %s) = %v, want %v", tc.url, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCookieSetRejectsInvalidOptions(t *testing.T) {
+	for _, options := range []string{`{ expires: "tomorrow" }`, `{ maxAge: "soon" }`, `"path=/"`} {
+		vm := cookieRuntime(t)
+		if _, err := vm.RunString(`jar.set("http://example.com/", "a", "1", ` + options + `)`); err == nil {
+			t.Errorf("options %s were accepted", options)
+		}
+	}
+}
+
+func TestCookieDeleteAndClear(t *testing.T) {
+	vm := cookieRuntime(t)
+	value, err := vm.RunString(`
+		jar.set("http://example.com/a/b", "a", "1", { path: "/a" });
+		jar.set("http://example.com/", "b", "2");
+		jar.set("http://example.com/", "c", "3");
+		jar.delete("http://example.com/a/b", "a");
+		const afterDelete = Object.keys(jar.cookiesForURL("http://example.com/a/b")).sort().join(",");
+		jar.clear("http://example.com/");
+		afterDelete + "|" + Object.keys(jar.cookiesForURL("http://example.com/a/b")).length;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := value.String(); got != "b,c|0" {
+		t.Errorf("cookies = %q, want b,c|0", got)
+	}
+}
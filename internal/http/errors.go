@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Error codes classify failed requests so scripts and checks can tell failures apart
+const (
+	errorCodeUnknown           = 1000
+	errorCodeTimeout           = 1050
+	errorCodeDNS               = 1100
+	errorCodeDial              = 1200
+	errorCodeConnectionRefused = 1210
+	errorCodeConnectionReset   = 1220
+	errorCodeTLS               = 1300
+	errorCodeInvalidRequest    = 1400
+	// errorCodeHTTP is added to 4xx and 5xx status codes, e.g. 1404 for 404 Not Found
+	errorCodeHTTP = 1000
+)
+
+// errorCode returns the error code of a failed request
+func errorCode(err error) int {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		return errorCodeInvalidRequest
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorCodeTimeout
+	case errors.As(err, &dnsErr):
+		return errorCodeDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errorCodeConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return errorCodeConnectionReset
+	case errors.As(err, &recordErr), errors.As(err, &certErr), errors.As(err, &unknownAuthority),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return errorCodeTLS
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return errorCodeDial
+	}
+	return errorCodeUnknown
+}
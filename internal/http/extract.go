@@ -0,0 +1,194 @@
+package http
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/dop251/goja"
+	"github.com/tidwall/gjson"
+)
+
+// compiledCacheSize bounds the compiled expressions kept per kind, so scripts building them dynamically cannot grow the caches forever
+const compiledCacheSize = 256
+
+// Compiled regular expressions and JSONPath expressions are cached, since scripts run the same ones every iteration
+var (
+	regexCache    = newLRUCache(compiledCacheSize)
+	jsonPathCache = newLRUCache(compiledCacheSize)
+)
+
+// extractor implements the body extraction methods of a response, parsing the body at most once per format
+type extractor struct {
+	vm   *goja.Runtime
+	body string
+
+	jsonDoc   gjson.Result
+	jsonValid bool
+	jsonOnce  sync.Once
+
+	htmlDoc  *goquery.Document
+	htmlErr  error
+	htmlOnce sync.Once
+
+	xmlDoc  *xmlquery.Node
+	xmlErr  error
+	xmlOnce sync.Once
+}
+
+// register sets the extraction methods on the response object
+func (e *extractor) register(obj *goja.Object) error {
+	for _, method := range []struct {
+		name string
+		fn   func(goja.FunctionCall) goja.Value
+	}{
+		{"jsonPath", e.jsonPath},
+		{"regex", e.regex},
+		{"regexAll", e.regexAll},
+		{"html", e.html},
+		{"xpath", e.xpath},
+		{"xpathAll", e.xpathAll},
+	} {
+		if err := obj.Set(method.name, method.fn); err != nil {
+			return fmt.Errorf("error setting response %s: %w", method.name, err)
+		}
+	}
+	return nil
+}
+
+// jsonPath returns every value matching a JSONPath expression, e.g. $.items[*].id
+func (e *extractor) jsonPath(call goja.FunctionCall) goja.Value {
+	expr := call.Argument(0).String()
+	path, err := jsonPathCache.get(expr, func() (interface{}, error) {
+		return parseJSONPath(expr)
+	})
+	if err != nil {
+		panic(e.vm.NewTypeError("invalid JSONPath %q: %v", expr, err))
+	}
+
+	e.jsonOnce.Do(func() {
+		if e.jsonValid = gjson.Valid(e.body); e.jsonValid {
+			e.jsonDoc = gjson.Parse(e.body)
+		}
+	})
+	if !e.jsonValid {
+		panic(e.vm.NewTypeError("response body is not valid JSON"))
+	}
+
+	matches := path.(jsonPathExpr).get(e.jsonDoc)
+	raw := make([]string, len(matches))
+	for i, match := range matches {
+		raw[i] = match.Raw
+	}
+	return e.parseJSON("[" + strings.Join(raw, ",") + "]")
+}
+
+// regex returns a capture group of the first match, or null when nothing matches.
+// The group defaults to the first capture group, or the whole match when the pattern has none.
+func (e *extractor) regex(call goja.FunctionCall) goja.Value {
+	re, group := e.compileRegex(call)
+	match := re.FindStringSubmatch(e.body)
+	if match == nil {
+		return goja.Null()
+	}
+	return e.vm.ToValue(match[group])
+}
+
+// regexAll returns a capture group of every match
+func (e *extractor) regexAll(call goja.FunctionCall) goja.Value {
+	re, group := e.compileRegex(call)
+	matches := re.FindAllStringSubmatch(e.body, -1)
+	values := make([]interface{}, len(matches))
+	for i, match := range matches {
+		values[i] = match[group]
+	}
+	return e.vm.NewArray(values...)
+}
+
+// compileRegex compiles the pattern argument and resolves the capture group argument
+func (e *extractor) compileRegex(call goja.FunctionCall) (*regexp.Regexp, int) {
+	pattern := call.Argument(0).String()
+	compiled, err := regexCache.get(pattern, func() (interface{}, error) {
+		return regexp.Compile(pattern)
+	})
+	if err != nil {
+		panic(e.vm.NewTypeError("invalid regular expression %q: %v", pattern, err))
+	}
+	re := compiled.(*regexp.Regexp)
+
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	if arg := call.Argument(1); !goja.IsUndefined(arg) {
+		group = int(arg.ToInteger())
+	}
+	if group < 0 || group > re.NumSubexp() {
+		panic(e.vm.NewTypeError("regular expression %q has no capture group %d", pattern, group))
+	}
+	return re, group
+}
+
+// html parses the body as an HTML document and returns a selection of it, narrowed by an optional CSS selector
+func (e *extractor) html(call goja.FunctionCall) goja.Value {
+	e.htmlOnce.Do(func() {
+		e.htmlDoc, e.htmlErr = goquery.NewDocumentFromReader(strings.NewReader(e.body))
+	})
+	if e.htmlErr != nil {
+		panic(e.vm.NewGoError(fmt.Errorf("error parsing HTML: %w", e.htmlErr)))
+	}
+
+	selection := e.htmlDoc.Selection
+	if selector := call.Argument(0); !goja.IsUndefined(selector) {
+		selection = selection.Find(selector.String())
+	}
+	return newSelection(e.vm, selection)
+}
+
+// xpath returns the text of the first XML node matching the expression, or null when nothing matches
+func (e *extractor) xpath(call goja.FunctionCall) goja.Value {
+	nodes := e.queryXML(call.Argument(0).String())
+	if len(nodes) == 0 {
+		return goja.Null()
+	}
+	return e.vm.ToValue(nodes[0].InnerText())
+}
+
+// xpathAll returns the text of every XML node matching the expression
+func (e *extractor) xpathAll(call goja.FunctionCall) goja.Value {
+	nodes := e.queryXML(call.Argument(0).String())
+	values := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		values[i] = node.InnerText()
+	}
+	return e.vm.NewArray(values...)
+}
+
+// queryXML parses the body as an XML document and returns the nodes matching the expression
+func (e *extractor) queryXML(expr string) []*xmlquery.Node {
+	e.xmlOnce.Do(func() {
+		e.xmlDoc, e.xmlErr = xmlquery.Parse(strings.NewReader(e.body))
+	})
+	if e.xmlErr != nil {
+		panic(e.vm.NewGoError(fmt.Errorf("error parsing XML: %w", e.xmlErr)))
+	}
+
+	nodes, err := xmlquery.QueryAll(e.xmlDoc, expr)
+	if err != nil {
+		panic(e.vm.NewTypeError("invalid XPath %q: %v", expr, err))
+	}
+	return nodes
+}
+
+// parseJSON parses JSON text into a value of the runtime
+func (e *extractor) parseJSON(text string) goja.Value {
+	parse, _ := goja.AssertFunction(e.vm.Get("JSON").ToObject(e.vm).Get("parse"))
+	value, err := parse(goja.Undefined(), e.vm.ToValue(text))
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"fmt"
+	"testing"
+)
+
+// extractResult runs code against a response with the given body and returns its result as JSON
+func extractResult(
+	t *testing.T,
+	body string,
+	code string,
+) string {
+	t.Helper()
+	vm := responseValue(t, &Response{Status: 200, Body: []byte(body)})
+	value, err := vm.RunString("JSON.stringify(" + code + ")")
+	if err != nil {
+		t.Fatalf("%s: %v", code, err)
+	}
+	return value.String()
+}
+
+func TestJSONPath(t *testing.T) {
+	body := `{"items":[{"id":1,"tags":["a","b"]},{"id":2,"tags":["c"]}],"meta":{"id":"m","total":2},"odd key":true}`
+	for expr, want := range map[string]string{
+		"$.items[*].id":      `[1,2]`,
+		"$.items[0].tags":    `[["a","b"]]`,
+		"$.items[-1].id":     `[2]`,
+		"$['meta']['total']": `[2]`,
+		`$["odd key"]`:       `[true]`,
+		"$.items[*].tags[*]": `["a","b","c"]`,
+		"$..id":              `[1,2,"m"]`,
+		"$.meta.*":           `["m",2]`,
+		"$.missing":          `[]`,
+		"$.items[5]":         `[]`,
+	} {
+		if got := extractResult(t, body, fmt.Sprintf("res.jsonPath(%q)", expr)); got != want {
+			t.Errorf("jsonPath(%s) = %s, want %s", expr, got, want)
+		}
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, tc := range []struct{ body, expr string }{
+		{`{"a":1}`, "$.a[?(@.b)]"},
+		{`{"a":1}`, "$.a[0"},
+		{`not json`, "$.a"},
+	} {
+		vm := responseValue(t, &Response{Status: 200, Body: []byte(tc.body)})
+		if _, err := vm.RunString(fmt.Sprintf("res.jsonPath(%q)", tc.expr)); err == nil {
+			t.Errorf("jsonPath(%s) on %s did not throw", tc.expr, tc.body)
+		}
+	}
+}
+
+func TestRegex(t *testing.T) {
+	body := "sessionId=abc123; token=xyz; sessionId=def456"
+	for code, want := range map[string]string{
+		`res.regex("sessionId=(\\w+)")`:    `"abc123"`,
+		`res.regex("sessionId=\\w+")`:      `"sessionId=abc123"`,
+		`res.regex("(\\w+)=(\\w+)", 2)`:    `"abc123"`,
+		`res.regex("missing=(\\w+)")`:      `null`,
+		`res.regexAll("sessionId=(\\w+)")`: `["abc123","def456"]`,
+		`res.regexAll("(\\w+)=\\w+", 0)`:   `["sessionId=abc123","token=xyz","sessionId=def456"]`,
+	} {
+		if got := extractResult(t, body, code); got != want {
+			t.Errorf("%s = %s, want %s", code, got, want)
+		}
+	}
+
+	vm := responseValue(t, &Response{Status: 200, Body: []byte(body)})
+	if _, err := vm.RunString(`res.regex("(a)", 2)`); err == nil {
+		t.Error("missing capture group did not throw")
+	}
+}
+
+func TestHTMLAndXPath(t *testing.T) {
+	page := `<html><body><input name="csrf" value="t0k"><a class="p" href="/1">One</a><a class="p" href="/2">Two</a></body></html>`
+	if got := extractResult(t, page, `res.html("input[name=csrf]").val()`); got != `"t0k"` {
+		t.Errorf("csrf = %s", got)
+	}
+	if got := extractResult(t, page, `res.html("a.p").map((i, a) => a.attr("href"))`); got != `["/1","/2"]` {
+		t.Errorf("links = %s", got)
+	}
+
+	doc := `<catalog><book id="1"><title>Go</title></book><book id="2"><title>JS</title></book></catalog>`
+	if got := extractResult(t, doc, `res.xpath("//book[@id='2']/title")`); got != `"JS"` {
+		t.Errorf("xpath = %s", got)
+	}
+	if got := extractResult(t, doc, `res.xpathAll("//title")`); got != `["Go","JS"]` {
+		t.Errorf("xpathAll = %s", got)
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newLRUCache(2)
+	compiles := 0
+	get := func(key string) {
+		if _, err := cache.get(key, func() (interface{}, error) {
+			compiles++
+			return key, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get("a")
+	get("b")
+	get("a")
+	get("c") // evicts b
+	get("a")
+	if compiles != 3 {
+		t.Errorf("compiled %d times, want 3", compiles)
+	}
+	get("b")
+	if compiles != 4 || cache.order.Len() != 2 {
+		t.Errorf("compiled %d times with %d entries, want 4 and 2", compiles, cache.order.Len())
+	}
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// jsonPathStep is one step of a JSONPath expression, selecting a member, an index or every child
+type jsonPathStep struct {
+	// name is the member selected, empty for index and wildcard steps
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+	// recursive applies the step to the value and all its descendants, as in $..name
+	recursive bool
+}
+
+// jsonPathExpr is a parsed JSONPath expression supporting members, indexes, wildcards and recursive descent
+type jsonPathExpr []jsonPathStep
+
+// parseJSONPath parses expressions such as $.items[*].id, $['items'][0] or $..id
+func parseJSONPath(expr string) (jsonPathExpr, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	var path jsonPathExpr
+	for rest != "" {
+		var step jsonPathStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(rest, "."):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("missing member name at %q", rest)
+			}
+			if rest[:end] == "*" {
+				step.wildcard = true
+			} else {
+				step.name = rest[:end]
+			}
+			path = append(path, step)
+			rest = rest[end:]
+			continue
+		case !strings.HasPrefix(rest, "["):
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("missing ] in %q", rest)
+		}
+		selector := strings.TrimSpace(rest[1:end])
+		rest = rest[end+1:]
+		switch {
+		case selector == "*":
+			step.wildcard = true
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			step.name = selector[1 : len(selector)-1]
+		default:
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("unsupported selector [%s]", selector)
+			}
+			step.index = index
+			step.isIndex = true
+		}
+		path = append(path, step)
+	}
+	return path, nil
+}
+
+// get returns every value of the document matching the expression
+func (p jsonPathExpr) get(doc gjson.Result) []gjson.Result {
+	values := []gjson.Result{doc}
+	for _, step := range p {
+		var next []gjson.Result
+		for _, value := range values {
+			if step.recursive {
+				for _, descendant := range descendants(value) {
+					next = append(next, step.apply(descendant)...)
+				}
+			} else {
+				next = append(next, step.apply(value)...)
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// apply returns the children of value selected by the step
+func (s jsonPathStep) apply(value gjson.Result) []gjson.Result {
+	switch {
+	case s.wildcard:
+		var children []gjson.Result
+		value.ForEach(func(_, child gjson.Result) bool {
+			children = append(children, child)
+			return true
+		})
+		return children
+	case s.isIndex:
+		if !value.IsArray() {
+			return nil
+		}
+		elements := value.Array()
+		index := s.index
+		if index < 0 {
+			index += len(elements)
+		}
+		if index < 0 || index >= len(elements) {
+			return nil
+		}
+		return []gjson.Result{elements[index]}
+	}
+
+	if !value.IsObject() {
+		return nil
+	}
+	var member []gjson.Result
+	value.ForEach(func(key, child gjson.Result) bool {
+		if key.String() == s.name {
+			member = append(member, child)
+			return false
+		}
+		return true
+	})
+	return member
+}
+
+// descendants returns value followed by all the values nested in it, depth first
+func descendants(value gjson.Result) []gjson.Result {
+	all := []gjson.Result{value}
+	if value.IsObject() || value.IsArray() {
+		value.ForEach(func(_, child gjson.Result) bool {
+			all = append(all, descendants(child)...)
+			return true
+		})
+	}
+	return all
+}
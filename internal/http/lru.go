@@ -0,0 +1,64 @@
+package http
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruEntry is a key and value stored in an lruCache
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// lruCache is a concurrency-safe cache holding up to size values, evicting the least recently used one
+type lruCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// newLRUCache creates an lruCache holding up to size values
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the value stored under key, compiling and storing it if there is none.
+// Compile errors are not cached.
+func (c *lruCache) get(
+	key string,
+	compile func() (interface{}, error),
+) (interface{}, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+		return element.Value.(*lruEntry).value, nil
+	}
+	c.mu.Unlock()
+
+	value, err := compile()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*lruEntry).value, nil
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	return value, nil
+}
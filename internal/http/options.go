@@ -0,0 +1,149 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// requestOptions holds the per-request options of a request config
+type requestOptions struct {
+	// timeout overrides the client timeout when greater than zero
+	timeout time.Duration
+	// redirects is the maximum number of redirects to follow, or -1 for the client default
+	redirects   int
+	params      url.Values
+	auth        *authOptions
+	compression string
+}
+
+// authOptions holds the credentials of a request
+type authOptions struct {
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+const (
+	authBasic  = "basic"
+	authBearer = "bearer"
+	authDigest = "digest"
+)
+
+// parseRequestOptions reads the timeout, redirects, params, auth and compression options of a request config
+func parseRequestOptions(config map[string]interface{}) (*requestOptions, error) {
+	options := &requestOptions{redirects: -1}
+
+	switch timeout := config["timeout"].(type) {
+	case nil:
+	case string:
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		options.timeout = d
+	case int64:
+		options.timeout = time.Duration(timeout) * time.Millisecond
+	case float64:
+		options.timeout = time.Duration(timeout * float64(time.Millisecond))
+	default:
+		return nil, fmt.Errorf("invalid timeout, expected a duration string or milliseconds")
+	}
+
+	switch redirects := config["redirects"].(type) {
+	case nil:
+	case int64:
+		options.redirects = int(redirects)
+	case bool:
+		if !redirects {
+			options.redirects = 0
+		}
+	default:
+		return nil, fmt.Errorf("invalid redirects, expected a number or false")
+	}
+	if options.redirects < -1 {
+		return nil, fmt.Errorf("redirects cannot be negative")
+	}
+
+	if params, ok := config["params"].(map[string]interface{}); ok {
+		options.params = url.Values{}
+		for key, value := range params {
+			if elements, ok := value.([]interface{}); ok {
+				for _, element := range elements {
+					options.params.Add(key, fmt.Sprint(element))
+				}
+				continue
+			}
+			options.params.Add(key, fmt.Sprint(value))
+		}
+	}
+
+	if auth, ok := config["auth"].(map[string]interface{}); ok {
+		options.auth = &authOptions{}
+		options.auth.Type, _ = auth["type"].(string)
+		options.auth.Username, _ = auth["username"].(string)
+		options.auth.Password, _ = auth["password"].(string)
+		options.auth.Token, _ = auth["token"].(string)
+		switch options.auth.Type {
+		case authBasic, authBearer, authDigest:
+		default:
+			return nil, fmt.Errorf("invalid auth type %q, expected basic, bearer or digest", options.auth.Type)
+		}
+	}
+
+	if compression, ok := config["compression"].(string); ok {
+		if _, err := compress(nil, compression); err != nil {
+			return nil, err
+		}
+		options.compression = compression
+	}
+
+	return options, nil
+}
+
+// withParams returns the URL with the params added to its query string
+func withParams(
+	rawURL string,
+	params url.Values,
+) (string, error) {
+	if len(params) == 0 {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// httpClient returns the client to send a request with, adjusted for its timeout and redirect options
+func (c *Client) httpClient(options *requestOptions) *http.Client {
+	if options.timeout <= 0 && options.redirects < 0 {
+		return c.client
+	}
+
+	client := *c.client
+	if options.timeout > 0 {
+		client.Timeout = options.timeout
+	}
+	if options.redirects >= 0 {
+		maxRedirects := options.redirects
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				// Return the redirect response itself instead of failing the request
+				return http.ErrUseLastResponse
+			}
+			return renewTraceparent(req)
+		}
+	}
+	return &client
+}
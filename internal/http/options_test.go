@@ -0,0 +1,81 @@
+package http
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRequestOptions(t *testing.T) {
+	options, err := parseRequestOptions(map[string]interface{}{
+		"timeout":     "2s",
+		"redirects":   false,
+		"params":      map[string]interface{}{"page": int64(2), "tag": []interface{}{"a", "b"}},
+		"auth":        map[string]interface{}{"type": "bearer", "token": "t0k"},
+		"compression": "gzip",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.timeout != 2*time.Second || options.redirects != 0 || options.compression != "gzip" {
+		t.Errorf("unexpected options %+v", options)
+	}
+	if got := options.params.Encode(); got != "page=2&tag=a&tag=b" {
+		t.Errorf("params = %s", got)
+	}
+	if options.auth.Type != authBearer || options.auth.Token != "t0k" {
+		t.Errorf("auth = %+v", options.auth)
+	}
+
+	for _, tc := range []struct {
+		timeout interface{}
+		want    time.Duration
+	}{
+		{int64(1500), 1500 * time.Millisecond},
+		{float64(2.5), 2500 * time.Microsecond},
+	} {
+		options, err := parseRequestOptions(map[string]interface{}{"timeout": tc.timeout})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if options.timeout != tc.want {
+			t.Errorf("timeout %v = %v, want %v", tc.timeout, options.timeout, tc.want)
+		}
+	}
+
+	defaults, err := parseRequestOptions(map[string]interface{}{"redirects": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaults.redirects != -1 || defaults.timeout != 0 || defaults.auth != nil {
+		t.Errorf("unexpected defaults %+v", defaults)
+	}
+}
+
+func TestParseRequestOptionsRejectsInvalidValues(t *testing.T) {
+	for name, config := range map[string]map[string]interface{}{
+		"timeout string":     {"timeout": "soon"},
+		"timeout type":       {"timeout": true},
+		"negative redirects": {"redirects": int64(-2)},
+		"redirects type":     {"redirects": "many"},
+		"auth type":          {"auth": map[string]interface{}{"type": "ntlm"}},
+		"compression":        {"compression": "zip"},
+	} {
+		if _, err := parseRequestOptions(config); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+}
+
+func TestWithParams(t *testing.T) {
+	got, err := withParams("https://example.com/search?q=go", url.Values{"page": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://example.com/search?page=2&q=go" {
+		t.Errorf("url = %s", got)
+	}
+	if got, _ := withParams("https://example.com/?a=1", nil); got != "https://example.com/?a=1" {
+		t.Errorf("url without params = %s", got)
+	}
+}
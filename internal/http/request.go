@@ -2,15 +2,25 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
 )
 
-// Fetch performs an HTTP request based on the provided configuration
-func (c *Client) Fetch(config map[string]interface{}) (map[string]interface{}, error) {
+// errInvalidRequest is returned when the request config cannot be turned into a request
+var errInvalidRequest = errors.New("invalid request")
+
+// Fetch performs an HTTP request based on the provided configuration.
+// When the request fails, the returned Response still holds the URL and the timings measured before the failure.
+func (c *Client) Fetch(
+	ctx context.Context,
+	config map[string]interface{},
+) (*Response, error) {
 	method, ok := config["method"].(string)
 	if !ok {
 		method = "GET"
@@ -18,19 +28,86 @@ func (c *Client) Fetch(config map[string]interface{}) (map[string]interface{}, e
 
 	url, ok := config["url"].(string)
 	if !ok || url == "" {
-		return nil, errors.New("url is required and must be a string")
+		return nil, fmt.Errorf("%w: url is required and must be a string", errInvalidRequest)
+	}
+
+	options, err := parseRequestOptions(config)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+	if url, err = withParams(url, options.params); err != nil {
+		return nil, fmt.Errorf("%w: error adding params: %v", errInvalidRequest, err)
+	}
+
+	encoded, err := encodeBody(config["body"], headerValue(config, "Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if encoded != nil && options.compression != "" {
+		data, err := compress(encoded.data, options.compression)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidRequest, err)
+		}
+		encoded = &requestBody{data: data, contentType: encoded.contentType}
+	}
+
+	var requestMetrics metrics.RequestMetrics
+	ctx = metrics.WithObserver(ctx, func(m metrics.RequestMetrics) {
+		requestMetrics = m
+	})
+
+	req, err := c.newRequest(ctx, method, url, config, encoded, options)
+	if err != nil {
+		return nil, err
 	}
 
+	client := c.httpClient(options)
+	resp, err := client.Do(req)
+	if err == nil && options.auth != nil && options.auth.Type == authDigest {
+		if challenge := digestChallenge(resp); challenge != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			resp, err = c.retryWithDigest(ctx, client, method, url, config, encoded, options, challenge)
+		}
+	}
+	if err != nil {
+		return failedResponse(url, err, requestMetrics), fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	encodedBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return failedResponse(url, err, requestMetrics), fmt.Errorf("error reading response body: %w", err)
+	}
+	// Like net/http, only decode bodies that are there, so HEAD and 204 responses keep their Content-Encoding header
+	responseBody := encodedBody
+	if len(encodedBody) > 0 && resp.Request.Method != http.MethodHead {
+		if responseBody, err = decompress(encodedBody, resp.Header.Get("Content-Encoding")); err != nil {
+			return failedResponse(url, err, requestMetrics), err
+		}
+	}
+
+	response := newResponse(resp, responseBody, requestMetrics)
+	response.EncodedBodySize = len(encodedBody)
+	return response, nil
+}
+
+// newRequest creates the request for a config, setting its headers, body and credentials
+func (c *Client) newRequest(
+	ctx context.Context,
+	method, url string,
+	config map[string]interface{},
+	encoded *requestBody,
+	options *requestOptions,
+) (*http.Request, error) {
 	var body io.Reader
-	if bodyStr, ok := config["body"].(string); ok {
-		body = bytes.NewBufferString(bodyStr)
-	} else {
-		body = nil
+	if encoded != nil {
+		body = bytes.NewReader(encoded.data)
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("%w: error creating request: %v", errInvalidRequest, err)
 	}
 
 	if headers, ok := config["headers"].(map[string]interface{}); ok {
@@ -42,24 +119,51 @@ func (c *Client) Fetch(config map[string]interface{}) (map[string]interface{}, e
 			}
 		}
 	}
+	if encoded != nil && encoded.contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", encoded.contentType)
+	}
+	if options.compression != "" && encoded != nil {
+		req.Header.Set("Content-Encoding", options.compression)
+	}
+	// Asking for compressed responses ourselves keeps the transport from decompressing them,
+	// so the encoded size can be reported next to the decoded one
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	if options.auth != nil {
+		setAuth(req, options.auth)
+	}
+	// Close works for both transports, unlike DisableKeepAlives which HTTP/2 ignores
+	req.Close = c.noConnectionReuse
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	if c.tracePropagation {
+		traceparent, err := newTraceparent()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(TraceparentHeader, traceparent)
 	}
-	defer resp.Body.Close()
+	return req, nil
+}
 
-	responseBody, err := io.ReadAll(resp.Body)
+// retryWithDigest sends the request again, answering the Digest challenge of the first response
+func (c *Client) retryWithDigest(
+	ctx context.Context,
+	client *http.Client,
+	method, url string,
+	config map[string]interface{},
+	encoded *requestBody,
+	options *requestOptions,
+	challenge map[string]string,
+) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, url, config, encoded, options)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return nil, err
 	}
-
-	responseDetails := map[string]interface{}{
-		"statusCode":    resp.StatusCode,
-		"statusMessage": resp.Status,
-		"headers":       resp.Header,
-		"body":          string(responseBody),
+	authorization, err := digestAuthorization(req, options.auth, challenge)
+	if err != nil {
+		return nil, err
 	}
-
-	return responseDetails, nil
+	req.Header.Set("Authorization", authorization)
+	return client.Do(req)
 }
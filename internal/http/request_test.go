@@ -0,0 +1,81 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// testClient returns the client of a virtual user with default options
+func testClient(t *testing.T) *Client {
+	t.Helper()
+	template, err := NewClient(metrics.NewMetrics(nil), &models.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := template.ForVirtualUser(NewCookieJar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestFetchGzipHeaderWithoutBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := testClient(t)
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		resp, err := client.Fetch(context.Background(), map[string]interface{}{"method": method, "url": server.URL})
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if resp.Status != http.StatusOK || len(resp.Body) != 0 || resp.Error != "" {
+			t.Errorf("%s: status %d, body %q, error %q", method, resp.Status, resp.Body, resp.Error)
+		}
+	}
+}
+
+func TestFetchDecodesCompressedBodies(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write(bytes.Repeat([]byte("a"), 1000))
+	writer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unknown" {
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write([]byte("raw"))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped.Bytes())
+	}))
+	defer server.Close()
+
+	client := testClient(t)
+	resp, err := client.Fetch(context.Background(), map[string]interface{}{"url": server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Body) != 1000 || resp.EncodedBodySize != gzipped.Len() {
+		t.Errorf("body of %d bytes encoded as %d, want 1000 encoded as %d", len(resp.Body), resp.EncodedBodySize, gzipped.Len())
+	}
+
+	resp, err = client.Fetch(context.Background(), map[string]interface{}{"url": server.URL + "/unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "raw" {
+		t.Errorf("unknown encoding body = %q, want raw", resp.Body)
+	}
+}
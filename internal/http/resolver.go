@@ -0,0 +1,302 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// hostOverride points the host names matching a pattern at a fixed address
+type hostOverride struct {
+	pattern string
+	ip      net.IP
+	// port replaces the port of the request when not empty
+	port string
+}
+
+// cachedAddrs holds the resolved addresses of a host until they expire
+type cachedAddrs struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+// resolver resolves host names for the dialer, applying the hosts overrides and the DNS options.
+// It is shared by the transports of all virtual users, so they share its cache.
+type resolver struct {
+	overrides  []hostOverride
+	ttl        time.Duration
+	selectMode string
+	policy     string
+
+	mu    sync.Mutex
+	cache map[string]cachedAddrs
+	// next holds the round-robin position per host
+	next map[string]int
+}
+
+// minDialShare is the least time a candidate address gets to connect, like the minimum of net.Dialer
+const minDialShare = 2 * time.Second
+
+// newResolver creates the resolver for the hosts and DNS options, or nil when neither is set.
+// It is also how ValidateOptions checks these options, so they are parsed in one place.
+func newResolver(options *models.Options) (*resolver, error) {
+	if len(options.Hosts) == 0 && options.DNS == nil {
+		return nil, nil
+	}
+
+	r := &resolver{
+		selectMode: models.DNSSelectFirst,
+		cache:      make(map[string]cachedAddrs),
+		next:       make(map[string]int),
+	}
+	if dns := options.DNS; dns != nil {
+		if dns.TTL != "" {
+			ttl, err := time.ParseDuration(dns.TTL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dns ttl: %w", err)
+			}
+			if ttl < 0 {
+				return nil, fmt.Errorf("dns ttl cannot be negative")
+			}
+			r.ttl = ttl
+		}
+		switch dns.Select {
+		case "":
+		case models.DNSSelectFirst, models.DNSSelectRandom, models.DNSSelectRoundRobin:
+			r.selectMode = dns.Select
+		default:
+			return nil, fmt.Errorf("invalid dns select %q, expected first, random or roundRobin", dns.Select)
+		}
+		switch dns.Policy {
+		case "", models.DNSPolicyPreferIPv4, models.DNSPolicyPreferIPv6:
+			r.policy = dns.Policy
+		default:
+			return nil, fmt.Errorf("invalid dns policy %q, expected preferIPv4 or preferIPv6", dns.Policy)
+		}
+	}
+
+	for pattern, address := range options.Hosts {
+		override, err := parseHostOverride(pattern, address)
+		if err != nil {
+			return nil, err
+		}
+		r.overrides = append(r.overrides, override)
+	}
+	// Exact host names win over wildcards, and longer wildcards over shorter ones
+	sort.Slice(r.overrides, func(i, j int) bool {
+		a, b := r.overrides[i].pattern, r.overrides[j].pattern
+		aWildcard, bWildcard := strings.HasPrefix(a, "*."), strings.HasPrefix(b, "*.")
+		if aWildcard != bWildcard {
+			return !aWildcard
+		}
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return r, nil
+}
+
+// parseHostOverride parses an IP address with an optional port
+func parseHostOverride(
+	pattern string,
+	address string,
+) (hostOverride, error) {
+	override := hostOverride{pattern: strings.ToLower(pattern)}
+	if ip := net.ParseIP(address); ip != nil {
+		override.ip = ip
+		return override, nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err == nil {
+		if _, err = strconv.ParseUint(port, 10, 16); err == nil {
+			override.ip = net.ParseIP(host)
+		}
+	}
+	if override.ip == nil {
+		return hostOverride{}, fmt.Errorf("invalid hosts address %q for %q, expected an IP address with an optional port", address, pattern)
+	}
+	override.port = port
+	return override, nil
+}
+
+// override returns the hosts override matching host
+func (r *resolver) override(host string) (hostOverride, bool) {
+	for _, override := range r.overrides {
+		if matchDomain(override.pattern, host) {
+			return override, true
+		}
+	}
+	return hostOverride{}, false
+}
+
+// dialContext returns a dial function connecting to the addresses chosen by the resolver.
+// DNS lookups are reported to the httptrace hooks of the request, also when served from the cache.
+// Like net.Dialer, the dialer timeout covers all the candidate addresses together.
+func (r *resolver) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if override, ok := r.override(host); ok {
+			if override.port != "" {
+				port = override.port
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(override.ip.String(), port))
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := r.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		addrs, preferred := r.order(network, addrs)
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+		}
+
+		// Fall back to the other addresses when the chosen one does not accept the connection
+		chosen := r.pick(host, preferred)
+		candidates := append([]net.IPAddr{addrs[chosen]}, addrs[:chosen]...)
+		candidates = append(candidates, addrs[chosen+1:]...)
+
+		deadline := time.Now().Add(dialer.Timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+
+		var firstErr error
+		for i, candidate := range candidates {
+			// Every candidate gets an equal share of the time left, so an unresponsive one cannot use it all
+			share := max(time.Until(deadline)/time.Duration(len(candidates)-i), minDialShare)
+			attemptCtx, cancelAttempt := context.WithTimeout(ctx, share)
+			conn, err := dialer.DialContext(attemptCtx, network, net.JoinHostPort(candidate.IP.String(), port))
+			cancelAttempt()
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, firstErr
+	}
+}
+
+// resolve returns the addresses of host, from the cache while they have not expired
+func (r *resolver) resolve(
+	ctx context.Context,
+	host string,
+) ([]net.IPAddr, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+
+	addrs, err := r.lookup(ctx, host)
+
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: err})
+	}
+	return addrs, err
+}
+
+// lookup returns the cached addresses of host or resolves them
+func (r *resolver) lookup(
+	ctx context.Context,
+	host string,
+) ([]net.IPAddr, error) {
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.addrs, nil
+	}
+
+	// The lookup must not fire the hooks of the request again, so it runs on a context without its values
+	lookupCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(lookupCtx, host)
+	if err != nil {
+		return nil, err
+	}
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[host] = cachedAddrs{addrs: addrs, expires: now.Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+// order filters the addresses usable on network and sorts them by the address family policy.
+// It also returns how many leading addresses belong to the preferred family, which are the ones selected from.
+func (r *resolver) order(
+	network string,
+	addrs []net.IPAddr,
+) ([]net.IPAddr, int) {
+	ordered := make([]net.IPAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		isIPv4 := addr.IP.To4() != nil
+		if (network == "tcp4" && !isIPv4) || (network == "tcp6" && isIPv4) {
+			continue
+		}
+		ordered = append(ordered, addr)
+	}
+
+	if r.policy != models.DNSPolicyPreferIPv4 && r.policy != models.DNSPolicyPreferIPv6 {
+		return ordered, len(ordered)
+	}
+	preferIPv4 := r.policy == models.DNSPolicyPreferIPv4
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return (ordered[i].IP.To4() != nil) == preferIPv4 && (ordered[j].IP.To4() != nil) != preferIPv4
+	})
+
+	preferred := 0
+	for preferred < len(ordered) && (ordered[preferred].IP.To4() != nil) == preferIPv4 {
+		preferred++
+	}
+	if preferred == 0 {
+		preferred = len(ordered)
+	}
+	return ordered, preferred
+}
+
+// pick returns the index of the address to connect to first
+func (r *resolver) pick(
+	host string,
+	count int,
+) int {
+	switch r.selectMode {
+	case models.DNSSelectRandom:
+		return rand.Intn(count)
+	case models.DNSSelectRoundRobin:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		index := r.next[host] % count
+		r.next[host] = index + 1
+		return index
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+func TestNewResolverValidatesOptions(t *testing.T) {
+	if r, err := newResolver(&models.Options{}); r != nil || err != nil {
+		t.Errorf("resolver without hosts or dns = %v, %v", r, err)
+	}
+
+	for name, options := range map[string]*models.Options{
+		"host without ip": {Hosts: map[string]string{"api.test": "backend:8080"}},
+		"host bad port":   {Hosts: map[string]string{"api.test": "10.0.0.1:"}},
+		"ttl":             {DNS: &models.DNSOptions{TTL: "often"}},
+		"negative ttl":    {DNS: &models.DNSOptions{TTL: "-1s"}},
+		"select":          {DNS: &models.DNSOptions{Select: "fastest"}},
+		"policy":          {DNS: &models.DNSOptions{Policy: "onlyIPv4"}},
+	} {
+		if _, err := newResolver(options); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+		if err := ValidateOptions(options); err == nil {
+			t.Errorf("%s passed ValidateOptions", name)
+		}
+	}
+
+	r, err := newResolver(&models.Options{
+		Hosts: map[string]string{"api.test": "10.0.0.1", "[::1]": "::1", "*.svc.test": "10.0.0.2:8443"},
+		DNS:   &models.DNSOptions{TTL: "1m", Select: models.DNSSelectRoundRobin, Policy: models.DNSPolicyPreferIPv6},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ttl != time.Minute || r.selectMode != models.DNSSelectRoundRobin || r.policy != models.DNSPolicyPreferIPv6 {
+		t.Errorf("unexpected resolver %+v", r)
+	}
+}
+
+func TestResolverOverridePrecedence(t *testing.T) {
+	r, err := newResolver(&models.Options{Hosts: map[string]string{
+		"*.example.com":      "10.0.0.1",
+		"*.api.example.com":  "10.0.0.2",
+		"v1.api.example.com": "10.0.0.3:8080",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for host, want := range map[string]string{
+		"V1.api.example.com": "10.0.0.3:8080",
+		"v2.api.example.com": "10.0.0.2:",
+		"www.example.com":    "10.0.0.1:",
+	} {
+		override, ok := r.override(host)
+		if !ok {
+			t.Errorf("%s has no override", host)
+			continue
+		}
+		if got := override.ip.String() + ":" + override.port; got != want {
+			t.Errorf("%s = %s, want %s", host, got, want)
+		}
+	}
+	if _, ok := r.override("example.com"); ok {
+		t.Error("wildcard matched its parent domain")
+	}
+}
+
+func TestResolverOrderAndPick(t *testing.T) {
+	addrs := []net.IPAddr{{IP: net.ParseIP("::1")}, {IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("10.0.0.2")}}
+
+	r := &resolver{policy: models.DNSPolicyPreferIPv4}
+	ordered, preferred := r.order("tcp", addrs)
+	if ordered[0].IP.String() != "10.0.0.1" || ordered[2].IP.String() != "::1" || preferred != 2 {
+		t.Errorf("preferIPv4 order = %v with %d preferred", ordered, preferred)
+	}
+	if ordered, _ := r.order("tcp6", addrs); len(ordered) != 1 {
+		t.Errorf("tcp6 kept %v", ordered)
+	}
+
+	r = &resolver{}
+	if ordered, preferred := r.order("tcp", addrs); ordered[0].IP.String() != "::1" || preferred != 3 {
+		t.Errorf("default order = %v with %d preferred", ordered, preferred)
+	}
+
+	r = &resolver{selectMode: models.DNSSelectRoundRobin, next: make(map[string]int)}
+	var picks []int
+	for i := 0; i < 4; i++ {
+		picks = append(picks, r.pick("api.test", 3))
+	}
+	if picks[0] != 0 || picks[1] != 1 || picks[2] != 2 || picks[3] != 0 {
+		t.Errorf("round robin picks = %v", picks)
+	}
+}
+
+func TestResolverDialFallsBackAndReportsCachedLookups(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	r, err := newResolver(&models.Options{DNS: &models.DNSOptions{TTL: "1m"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing listens on 127.0.0.2, so the dial falls back to 127.0.0.1
+	r.cache["multi.test"] = cachedAddrs{
+		addrs:   []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}},
+		expires: time.Now().Add(time.Minute),
+	}
+
+	var lookups []httptrace.DNSDoneInfo
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		DNSDone: func(info httptrace.DNSDoneInfo) { lookups = append(lookups, info) },
+	})
+	dial := r.dialContext(&net.Dialer{Timeout: 5 * time.Second})
+	conn, err := dial(ctx, "tcp", net.JoinHostPort("multi.test", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if got := conn.RemoteAddr().String(); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Errorf("connected to %s, want 127.0.0.1", got)
+	}
+	if len(lookups) != 1 || len(lookups[0].Addrs) != 2 || lookups[0].Coalesced {
+		t.Errorf("lookups = %+v, want one uncoalesced lookup of two addresses", lookups)
+	}
+}
+
+func TestResolverDialUsesHostsOverride(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	r, err := newResolver(&models.Options{Hosts: map[string]string{"api.test": listener.Addr().String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := r.dialContext(&net.Dialer{Timeout: 5 * time.Second})(context.Background(), "tcp", "api.test:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/tidwall/gjson"
+)
+
+// Response holds the outcome of a request as exposed to scripts
+type Response struct {
+	Status     int
+	StatusText string
+	// StatusLine is the status as sent by the server, e.g. "200 OK"
+	StatusLine string
+	Protocol   string
+	// URL is the URL of the last request, after following redirects
+	URL     string
+	Headers http.Header
+	// Body is the decompressed body, EncodedBodySize the size of the body as received
+	Body            []byte
+	EncodedBodySize int
+	Timings         metrics.Timings
+	// Error describes why the request failed, ErrorCode classifies the failure or the 4xx/5xx status
+	Error     string
+	ErrorCode int
+}
+
+// newResponse creates a Response from an HTTP response and the metrics of the request that produced it
+func newResponse(
+	resp *http.Response,
+	body []byte,
+	requestMetrics metrics.RequestMetrics,
+) *Response {
+	response := &Response{
+		Status:     resp.StatusCode,
+		StatusText: strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" "),
+		StatusLine: resp.Status,
+		Protocol:   resp.Proto,
+		URL:        resp.Request.URL.String(),
+		Headers:    resp.Header,
+		Body:       body,
+		Timings:    requestMetrics.Timings(),
+	}
+	if resp.StatusCode >= 400 {
+		response.ErrorCode = errorCodeHTTP + resp.StatusCode
+	}
+	return response
+}
+
+// failedResponse creates a Response for a request that did not complete
+func failedResponse(
+	url string,
+	err error,
+	requestMetrics metrics.RequestMetrics,
+) *Response {
+	return &Response{
+		URL:       url,
+		Headers:   http.Header{},
+		Timings:   requestMetrics.Timings(),
+		Error:     err.Error(),
+		ErrorCode: errorCode(err),
+	}
+}
+
+// toObject converts the Response into a JavaScript object
+func (r *Response) toObject(vm *goja.Runtime) (*goja.Object, error) {
+	timings := vm.NewObject()
+	for _, phase := range []struct {
+		name  string
+		value float64
+	}{
+		{"blocked", metrics.Milliseconds(r.Timings.Blocked)},
+		{"connecting", metrics.Milliseconds(r.Timings.Connecting)},
+		{"tls", metrics.Milliseconds(r.Timings.TLS)},
+		{"sending", metrics.Milliseconds(r.Timings.Sending)},
+		{"waiting", metrics.Milliseconds(r.Timings.Waiting)},
+		{"receiving", metrics.Milliseconds(r.Timings.Receiving)},
+		{"duration", metrics.Milliseconds(r.Timings.Duration)},
+	} {
+		if err := timings.Set(phase.name, phase.value); err != nil {
+			return nil, fmt.Errorf("error setting timing %s: %w", phase.name, err)
+		}
+	}
+
+	body := string(r.Body)
+	obj := vm.NewObject()
+	for _, field := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"status", r.Status},
+		{"statusText", r.StatusText},
+		// statusCode and statusMessage are kept for scripts written against the original response map
+		{"statusCode", r.Status},
+		{"statusMessage", r.StatusLine},
+		{"protocol", r.Protocol},
+		{"url", r.URL},
+		{"headers", map[string][]string(r.Headers)},
+		{"body", body},
+		{"bodySize", len(r.Body)},
+		{"encodedBodySize", r.EncodedBodySize},
+		{"timings", timings},
+		{"error", r.Error},
+		{"errorCode", r.ErrorCode},
+		{"json", r.jsonFunc(vm, body)},
+	} {
+		if err := obj.Set(field.name, field.value); err != nil {
+			return nil, fmt.Errorf("error setting response %s: %w", field.name, err)
+		}
+	}
+
+	extract := &extractor{vm: vm, body: body}
+	if err := extract.register(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// jsonFunc returns the json method of the response, which parses the body or the value at a GJSON selector.
+// A selector matching nothing returns undefined.
+func (r *Response) jsonFunc(
+	vm *goja.Runtime,
+	body string,
+) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		raw := body
+		if selector := call.Argument(0); !goja.IsUndefined(selector) && !goja.IsNull(selector) {
+			if !gjson.Valid(body) {
+				panic(vm.NewTypeError("response body is not valid JSON"))
+			}
+			result := gjson.Get(body, selector.String())
+			if !result.Exists() {
+				return goja.Undefined()
+			}
+			raw = result.Raw
+		}
+
+		parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+		value, err := parse(goja.Undefined(), vm.ToValue(raw))
+		if err != nil {
+			panic(err)
+		}
+		return value
+	}
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// responseValue converts the response into a JavaScript object bound to res in a new runtime
+func responseValue(
+	t *testing.T,
+	r *Response,
+) *goja.Runtime {
+	t.Helper()
+	vm := goja.New()
+	obj, err := r.toObject(vm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Set("res", obj); err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestResponseHeadersKeepEveryValue(t *testing.T) {
+	vm := responseValue(t, &Response{
+		Status: 200,
+		Headers: http.Header{
+			"Set-Cookie":   {"a=1", "b=2"},
+			"Content-Type": {"application/json"},
+		},
+	})
+
+	value, err := vm.RunString(`[res.headers["Set-Cookie"].length, res.headers["Set-Cookie"][1], res.headers["Content-Type"][0]].join("|")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := value.String(); got != "2|b=2|application/json" {
+		t.Errorf("headers = %q, want 2|b=2|application/json", got)
+	}
+}
+
+func TestResponseJSON(t *testing.T) {
+	vm := responseValue(t, &Response{Status: 200, Body: []byte(`{"items":[{"id":7}]}`)})
+
+	value, err := vm.RunString(`res.json().items[0].id + res.json("items.0.id") + (res.json("missing") === undefined ? 1 : 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := value.ToInteger(); got != 15 {
+		t.Errorf("json = %d, want 15", got)
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"fmt"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dop251/goja"
+)
+
+// newSelection wraps a goquery selection in a JavaScript object with jQuery-like methods
+func newSelection(
+	vm *goja.Runtime,
+	selection *goquery.Selection,
+) *goja.Object {
+	obj := vm.NewObject()
+	methods := []struct {
+		name string
+		fn   func(goja.FunctionCall) goja.Value
+	}{
+		// find narrows the selection to the descendants matching a CSS selector
+		{"find", func(call goja.FunctionCall) goja.Value {
+			return newSelection(vm, selection.Find(call.Argument(0).String()))
+		}},
+		// text returns the combined text of the selected elements
+		{"text", func(goja.FunctionCall) goja.Value {
+			return vm.ToValue(selection.Text())
+		}},
+		// html returns the inner HTML of the first selected element
+		{"html", func(goja.FunctionCall) goja.Value {
+			html, err := selection.Html()
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("error rendering HTML: %w", err)))
+			}
+			return vm.ToValue(html)
+		}},
+		// attr returns an attribute of the first selected element, or undefined when it is not set
+		{"attr", func(call goja.FunctionCall) goja.Value {
+			value, ok := selection.Attr(call.Argument(0).String())
+			if !ok {
+				return goja.Undefined()
+			}
+			return vm.ToValue(value)
+		}},
+		// val returns the value attribute of the first selected element
+		{"val", func(goja.FunctionCall) goja.Value {
+			return vm.ToValue(selection.AttrOr("value", ""))
+		}},
+		{"size", func(goja.FunctionCall) goja.Value {
+			return vm.ToValue(selection.Length())
+		}},
+		{"first", func(goja.FunctionCall) goja.Value {
+			return newSelection(vm, selection.First())
+		}},
+		{"last", func(goja.FunctionCall) goja.Value {
+			return newSelection(vm, selection.Last())
+		}},
+		{"eq", func(call goja.FunctionCall) goja.Value {
+			return newSelection(vm, selection.Eq(int(call.Argument(0).ToInteger())))
+		}},
+		// each calls fn(index, element) for every selected element
+		{"each", func(call goja.FunctionCall) goja.Value {
+			fn := selectionCallback(vm, call.Argument(0))
+			selection.Each(func(i int, s *goquery.Selection) {
+				if _, err := fn(goja.Undefined(), vm.ToValue(i), newSelection(vm, s)); err != nil {
+					panic(err)
+				}
+			})
+			return goja.Undefined()
+		}},
+		// map returns the results of calling fn(index, element) for every selected element
+		{"map", func(call goja.FunctionCall) goja.Value {
+			fn := selectionCallback(vm, call.Argument(0))
+			values := make([]interface{}, 0, selection.Length())
+			selection.Each(func(i int, s *goquery.Selection) {
+				value, err := fn(goja.Undefined(), vm.ToValue(i), newSelection(vm, s))
+				if err != nil {
+					panic(err)
+				}
+				values = append(values, value)
+			})
+			return vm.NewArray(values...)
+		}},
+	}
+	for _, method := range methods {
+		if err := obj.Set(method.name, method.fn); err != nil {
+			panic(vm.NewGoError(fmt.Errorf("error setting selection %s: %w", method.name, err)))
+		}
+	}
+	return obj
+}
+
+// selectionCallback asserts that the argument of each or map is a function
+func selectionCallback(
+	vm *goja.Runtime,
+	value goja.Value,
+) goja.Callable {
+	fn, ok := goja.AssertFunction(value)
+	if !ok {
+		panic(vm.NewTypeError("expected a function"))
+	}
+	return fn
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"strings"
+
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// tlsVersions maps the version names of options.tls to their constants
+var tlsVersions = map[string]uint16{
+	"tls1.0": tls.VersionTLS10,
+	"tls1.1": tls.VersionTLS11,
+	"tls1.2": tls.VersionTLS12,
+	"tls1.3": tls.VersionTLS13,
+}
+
+// domainCert is a client certificate and the domains it is presented to
+type domainCert struct {
+	domains []string
+	cert    tls.Certificate
+}
+
+// tlsSettings holds the TLS configuration built from options.tls
+type tlsSettings struct {
+	config *tls.Config
+	certs  []domainCert
+}
+
+// newTLSSettings builds the TLS configuration from options.tls, which may be nil
+func newTLSSettings(options *models.TLSOptions) (*tlsSettings, error) {
+	settings := &tlsSettings{config: &tls.Config{}}
+	if options == nil {
+		return settings, nil
+	}
+
+	config := settings.config
+	config.InsecureSkipVerify = options.InsecureSkipVerify
+
+	if options.MinVersion != "" {
+		version, ok := tlsVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls minVersion %q", options.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if options.MaxVersion != "" {
+		version, ok := tlsVersions[options.MaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls maxVersion %q", options.MaxVersion)
+		}
+		config.MaxVersion = version
+	}
+
+	if len(options.CipherSuites) > 0 {
+		ids := make(map[string]uint16)
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			ids[suite.Name] = suite.ID
+		}
+		for _, name := range options.CipherSuites {
+			id, ok := ids[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown tls cipher suite %q", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	if options.CACerts != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(options.CACerts)) {
+			return nil, fmt.Errorf("tls caCerts holds no valid PEM certificates")
+		}
+		config.RootCAs = pool
+	}
+
+	for i, clientCert := range options.ClientCerts {
+		cert, err := tls.X509KeyPair([]byte(clientCert.Cert), []byte(clientCert.Key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls client certificate %d: %w", i, err)
+		}
+		if len(clientCert.Domains) == 0 {
+			return nil, fmt.Errorf("tls client certificate %d has no domains", i)
+		}
+		settings.certs = append(settings.certs, domainCert{domains: clientCert.Domains, cert: cert})
+	}
+
+	return settings, nil
+}
+
+// configFor returns the TLS configuration for a host, with the client certificate of its domain if there is one
+func (s *tlsSettings) configFor(host string) *tls.Config {
+	config := s.config.Clone()
+	config.ServerName = host
+	for _, cert := range s.certs {
+		for _, domain := range cert.domains {
+			if matchDomain(domain, host) {
+				config.Certificates = []tls.Certificate{cert.cert}
+				return config
+			}
+		}
+	}
+	return config
+}
+
+// matchDomain reports whether host matches a domain, which may start with a *. wildcard matching any subdomain
+func matchDomain(domain, host string) bool {
+	domain, host = strings.ToLower(domain), strings.ToLower(host)
+	if suffix, ok := strings.CutPrefix(domain, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return domain == host
+}
+
+// tlsConn dials addr and wraps the connection in a TLS client configured for its host.
+// The handshake is left to the caller, since http.Transport performs and traces it itself.
+func (s *tlsSettings) tlsConn(
+	ctx context.Context,
+	dial func(ctx context.Context, network, addr string) (net.Conn, error),
+	network, addr string,
+	nextProtos []string,
+) (*tls.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := s.configFor(host)
+	config.NextProtos = nextProtos
+	return tls.Client(conn, config), nil
+}
+
+// handshake performs the TLS handshake, reporting it to the request's httptrace hooks
+func handshake(
+	ctx context.Context,
+	conn *tls.Conn,
+) error {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	err := conn.HandshakeContext(ctx)
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(conn.ConnectionState(), err)
+	}
+	if err != nil {
+		conn.Close()
+	}
+	return err
+}
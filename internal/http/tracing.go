@@ -0,0 +1,42 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C trace context header injected into requests
+const TraceparentHeader = "traceparent"
+
+// EnableTracePropagation makes the client inject a W3C traceparent header into every request
+func (c *Client) EnableTracePropagation() {
+	c.tracePropagation = true
+}
+
+// newTraceparent generates a traceparent header value with a random trace and span ID
+func newTraceparent() (string, error) {
+	var ids [24]byte
+	if _, err := rand.Read(ids[:]); err != nil {
+		return "", fmt.Errorf("error generating trace id: %w", err)
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(ids[:16]), hex.EncodeToString(ids[16:])), nil
+}
+
+// renewTraceparent gives a redirect carrying a traceparent header a new span ID in the same trace,
+// so every hop is reported as a span of its own
+func renewTraceparent(req *http.Request) error {
+	parts := strings.Split(req.Header.Get(TraceparentHeader), "-")
+	if len(parts) != 4 {
+		return nil
+	}
+	var spanID [8]byte
+	if _, err := rand.Read(spanID[:]); err != nil {
+		return fmt.Errorf("error generating span id: %w", err)
+	}
+	parts[2] = hex.EncodeToString(spanID[:])
+	req.Header.Set(TraceparentHeader, strings.Join(parts, "-"))
+	return nil
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewTraceparent(t *testing.T) {
+	format := regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`)
+	first, err := newTraceparent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newTraceparent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !format.MatchString(first) {
+		t.Errorf("traceparent %q does not match the W3C format", first)
+	}
+	if first == second {
+		t.Errorf("traceparent %q was generated twice", first)
+	}
+}
+
+func TestRedirectsGetNewSpanIDs(t *testing.T) {
+	var mu sync.Mutex
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get(TraceparentHeader))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/first":
+			http.Redirect(w, r, "/second", http.StatusFound)
+		case "/second":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client := testClient(t)
+	client.EnableTracePropagation()
+	// Both the default redirect policy and the one of the redirects option renew the span ID
+	for _, config := range []map[string]interface{}{
+		{"url": server.URL + "/first"},
+		{"url": server.URL + "/first", "redirects": int64(5)},
+	} {
+		traceparents = nil
+		resp, err := client.Fetch(context.Background(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != http.StatusOK || len(traceparents) != 3 {
+			t.Fatalf("status %d after %d requests", resp.Status, len(traceparents))
+		}
+
+		traceID := strings.Split(traceparents[0], "-")[1]
+		spanIDs := make(map[string]bool)
+		for _, traceparent := range traceparents {
+			parts := strings.Split(traceparent, "-")
+			if len(parts) != 4 || parts[1] != traceID {
+				t.Errorf("hop traceparent %q is not part of trace %s", traceparent, traceID)
+				continue
+			}
+			spanIDs[parts[2]] = true
+		}
+		if len(spanIDs) != len(traceparents) {
+			t.Errorf("hops share span IDs: %v", traceparents)
+		}
+	}
+}
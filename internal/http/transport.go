@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/models"
+	"golang.org/x/net/http2"
+)
+
+// ValidateOptions reports the first invalid TLS, HTTP version, hosts or DNS option, parsing them as the transports do
+func ValidateOptions(options *models.Options) error {
+	switch options.HTTPVersion {
+	case "", models.HTTPVersion1, models.HTTPVersion2, models.HTTPVersionH2C:
+	default:
+		return fmt.Errorf("invalid httpVersion %q, expected 1.1, 2 or h2c", options.HTTPVersion)
+	}
+	if _, err := newTLSSettings(options.TLS); err != nil {
+		return err
+	}
+	if _, err := newResolver(options); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newTransport creates the transport for the TLS and HTTP version options.
+// HTTP/2 is negotiated over TLS by default, options.httpVersion forces HTTP/1.1, HTTP/2 or HTTP/2 over cleartext.
+// The forced HTTP/2 transports connect directly, since x/net/http2 does not support proxies.
+// Connections are dialed through resolver when the hosts or DNS options are set.
+func newTransport(
+	options *models.Options,
+	resolver *resolver,
+) (http.RoundTripper, error) {
+	settings, err := newTLSSettings(options.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	dial := dialer.DialContext
+	if resolver != nil {
+		dial = resolver.dialContext(dialer)
+	}
+
+	switch options.HTTPVersion {
+	case models.HTTPVersion2, models.HTTPVersionH2C:
+		h2c := options.HTTPVersion == models.HTTPVersionH2C
+		transport := &http2.Transport{
+			AllowHTTP: h2c,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				if h2c {
+					return dial(ctx, network, addr)
+				}
+				conn, err := settings.tlsConn(ctx, dial, network, addr, []string{http2.NextProtoTLS})
+				if err != nil {
+					return nil, err
+				}
+				if err := handshake(ctx, conn); err != nil {
+					return nil, err
+				}
+				return conn, nil
+			},
+			IdleConnTimeout:  90 * time.Second,
+			ReadIdleTimeout:  30 * time.Second,
+			PingTimeout:      15 * time.Second,
+			WriteByteTimeout: 30 * time.Second,
+		}
+		if h2c {
+			return h2cTransport{transport}, nil
+		}
+		return transport, nil
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dial,
+		TLSClientConfig:       settings.config,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          1000,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConnsPerHost:   1000,
+		MaxConnsPerHost:       100,
+	}
+
+	nextProtos := []string{http2.NextProtoTLS, "http/1.1"}
+	if options.HTTPVersion == models.HTTPVersion1 {
+		// A non-nil, empty TLSNextProto disables HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		nextProtos = []string{"http/1.1"}
+	}
+
+	// Client certificates are chosen per domain, which needs the host of each connection
+	if len(settings.certs) > 0 {
+		transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return settings.tlsConn(ctx, dial, network, addr, nextProtos)
+		}
+	}
+	return transport, nil
+}
+
+// h2cTransport sends requests as HTTP/2 over cleartext connections.
+// It refuses https URLs, which the HTTP/2 transport would otherwise send unencrypted when AllowHTTP is set.
+type h2cTransport struct {
+	*http2.Transport
+}
+
+// RoundTrip implements http.RoundTripper
+func (t h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return nil, fmt.Errorf("%w: httpVersion h2c only supports http URLs, got %s", errInvalidRequest, req.URL.Redacted())
+	}
+	return t.Transport.RoundTrip(req)
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joakimcarlsson/yalt/internal/models"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestH2CTransport(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer server.Close()
+
+	transport, err := newTransport(&models.Options{HTTPVersion: models.HTTPVersionH2C}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s, want HTTP/2.0", resp.Proto)
+	}
+
+	if _, err := client.Get("https://example.com/"); !errors.Is(err, errInvalidRequest) {
+		t.Errorf("https URL error = %v, want errInvalidRequest", err)
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	for _, tc := range []struct {
+		domain, host string
+		want         bool
+	}{
+		{"api.example.com", "API.example.com", true},
+		{"api.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+	} {
+		if got := matchDomain(tc.domain, tc.host); got != tc.want {
+			t.Errorf("matchDomain(%s, %s) = %v, want %v", tc.domain, tc.host, got, tc.want)
+		}
+	}
+}
@@ -1,11 +1,7 @@
 package metrics
 
 import (
-	"bytes"
-	"fmt"
-	"io"
 	"net/http"
-	"sort"
 	"sync"
 	"time"
 )
@@ -14,28 +10,57 @@ import (
 type Metrics struct {
 	mu         sync.Mutex
 	requests   []RequestMetrics
+	iterations []time.Duration
+	checks     map[string]*CheckSummary
+	checkOrder []string
+	vuSamples  []VUSample
 	thresholds map[string][]string
 	startTime  time.Time
 }
 
+// VUSample records the number of active virtual users at a point in time
+type VUSample struct {
+	Time time.Time
+	VUs  int64
+}
+
 // RequestMetrics represents a single request metric
 type RequestMetrics struct {
 	DNSStart, DNSDone                   time.Time
 	ConnectStart, ConnectDone           time.Time
 	TLSHandshakeStart, TLSHandshakeDone time.Time
-	GotConn                             time.Time
+	GetConn, GotConn                    time.Time
 	WroteHeaders                        time.Time
 	WroteRequest                        time.Time
 	GotFirstResponseByte                time.Time
 	StartTime, EndTime                  time.Time
+	DataSent, DataReceived              int64
 	Request                             *http.Request
 	Response                            *http.Response
 	Error                               error
+	// ResponseDone is when the response body was read, while EndTime is when the response headers arrived
+	ResponseDone time.Time
+}
+
+// Duration returns the total duration of the request
+func (r RequestMetrics) Duration() time.Duration {
+	return r.EndTime.Sub(r.StartTime)
+}
+
+// Milliseconds converts a duration into fractional milliseconds
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Failed reports whether the request errored or returned a 4xx/5xx status
+func (r RequestMetrics) Failed() bool {
+	return r.Error != nil || (r.Response != nil && r.Response.StatusCode >= 400)
 }
 
 // NewMetrics creates a new Metrics instance
 func NewMetrics(thresholds map[string][]string) *Metrics {
 	return &Metrics{
+		checks:     make(map[string]*CheckSummary),
 		thresholds: thresholds,
 		startTime:  time.Now(),
 	}
@@ -48,271 +73,52 @@ func (m *Metrics) AddRequestMetrics(metrics RequestMetrics) {
 	m.mu.Unlock()
 }
 
-// CalculateAndDisplayMetrics calculates and displays the metrics
-func (m *Metrics) CalculateAndDisplayMetrics() {
+// RequestsSince returns a copy of the request metrics recorded after the given index
+func (m *Metrics) RequestsSince(index int) []RequestMetrics {
 	m.mu.Lock()
-	totalRequests := int64(len(m.requests))
-	requests := make([]RequestMetrics, totalRequests)
-	copy(requests, m.requests)
-	m.mu.Unlock()
-
-	totalDuration := time.Since(m.startTime)
-	rps := float64(totalRequests) / totalDuration.Seconds()
-
-	var failedRequests int64
-	var totalReqDuration, totalDNS, totalConnect, totalTLS, totalTTFB time.Duration
-	var totalDataSent, totalDataReceived int64
-	durations := make([]time.Duration, 0, totalRequests)
-	dnsDurations := make([]time.Duration, 0, totalRequests)
-	connectDurations := make([]time.Duration, 0, totalRequests)
-	tlsDurations := make([]time.Duration, 0, totalRequests)
-	ttfbDurations := make([]time.Duration, 0, totalRequests)
-
-	statusCodes := make(map[int]int)
-
-	for _, req := range requests {
-		duration := req.EndTime.Sub(req.StartTime)
-		durations = append(durations, duration)
-		totalReqDuration += duration
-
-		if req.Error != nil || (req.Response != nil && req.Response.StatusCode >= 400) {
-			failedRequests++
-		}
-
-		if req.Response != nil {
-			statusCodes[req.Response.StatusCode]++
-		}
-
-		totalDataSent += estimateRequestSize(req.Request)
-		if req.Response != nil {
-			totalDataReceived += estimateResponseSize(req.Response)
-		}
-
-		if !req.DNSStart.IsZero() && !req.DNSDone.IsZero() {
-			dnsDuration := req.DNSDone.Sub(req.DNSStart)
-			dnsDurations = append(dnsDurations, dnsDuration)
-			totalDNS += dnsDuration
-		}
-		if !req.ConnectStart.IsZero() && !req.ConnectDone.IsZero() {
-			connectDuration := req.ConnectDone.Sub(req.ConnectStart)
-			connectDurations = append(connectDurations, connectDuration)
-			totalConnect += connectDuration
-		}
-		if !req.TLSHandshakeStart.IsZero() && !req.TLSHandshakeDone.IsZero() {
-			tlsDuration := req.TLSHandshakeDone.Sub(req.TLSHandshakeStart)
-			tlsDurations = append(tlsDurations, tlsDuration)
-			totalTLS += tlsDuration
-		}
-		if !req.WroteRequest.IsZero() && !req.GotFirstResponseByte.IsZero() {
-			ttfbDuration := req.GotFirstResponseByte.Sub(req.WroteRequest)
-			ttfbDurations = append(ttfbDurations, ttfbDuration)
-			totalTTFB += ttfbDuration
-		}
-	}
-
-	minDuration, medianDuration, maxDuration, avgDuration := calculateMetrics(durations, totalReqDuration, totalRequests)
-	minDNS, medianDNS, maxDNS, avgDNS := calculateMetrics(dnsDurations, totalDNS, totalRequests)
-	minConnect, medianConnect, maxConnect, avgConnect := calculateMetrics(connectDurations, totalConnect, totalRequests)
-	minTLS, medianTLS, maxTLS, avgTLS := calculateMetrics(tlsDurations, totalTLS, totalRequests)
-	minTTFB, medianTTFB, maxTTFB, avgTTFB := calculateMetrics(ttfbDurations, totalTTFB, totalRequests)
-
-	p90Duration := calculatePercentile(durations, 90)
-	p95Duration := calculatePercentile(durations, 95)
-	p99Duration := calculatePercentile(durations, 99)
-
-	failureRate := float64(failedRequests) / float64(totalRequests)
-
-	convertBytes := func(bytes int64) string {
-		kb := float64(bytes) / 1024
-		mb := kb / 1024
-		if mb >= 1 {
-			return fmt.Sprintf("%.2f MB", mb)
-		} else if kb >= 1 {
-			return fmt.Sprintf("%.2f KB", kb)
-		}
-		return fmt.Sprintf("%d bytes", bytes)
-	}
-
-	dataRateSent := int64(float64(totalDataSent) / totalDuration.Seconds())
-	dataRateReceived := int64(float64(totalDataReceived) / totalDuration.Seconds())
-
-	format := func(label string, value interface{}) string {
-		return fmt.Sprintf("%-*s: %-*v", 25, label, 20, value)
+	defer m.mu.Unlock()
+	if index >= len(m.requests) {
+		return nil
 	}
-
-	fmt.Print(format("Total Requests", fmt.Sprintf("%d (%.2f/s)\n", totalRequests, rps)))
-	fmt.Print(format("Data Sent", fmt.Sprintf("%s (%s/s)\n", convertBytes(totalDataSent), convertBytes(dataRateSent))))
-	fmt.Print(format("Data Received", fmt.Sprintf("%s (%s/s)\n", convertBytes(totalDataReceived), convertBytes(dataRateReceived))))
-
-	fmt.Print(format("HTTP Request Duration", fmt.Sprintf("min=%7.2fms, med=%7.2fms, max=%7.2fms, avg=%7.2fms\n",
-		minDuration.Seconds()*1000, medianDuration.Seconds()*1000, maxDuration.Seconds()*1000, avgDuration.Seconds()*1000)))
-
-	fmt.Print(format("Percentiles", fmt.Sprintf("90th=%7.2fms, 95th=%7.2fms, 99th=%7.2fms\n",
-		p90Duration.Seconds()*1000, p95Duration.Seconds()*1000, p99Duration.Seconds()*1000)))
-
-	fmt.Print(format("DNS Lookup", fmt.Sprintf("min=%7.2fms, med=%7.2fms, max=%7.2fms, avg=%7.2fms\n",
-		minDNS.Seconds()*1000, medianDNS.Seconds()*1000, maxDNS.Seconds()*1000, avgDNS.Seconds()*1000)))
-
-	fmt.Print(format("TCP Connect", fmt.Sprintf("min=%7.2fms, med=%7.2fms, max=%7.2fms, avg=%7.2fms\n",
-		minConnect.Seconds()*1000, medianConnect.Seconds()*1000, maxConnect.Seconds()*1000, avgConnect.Seconds()*1000)))
-
-	fmt.Print(format("TLS Handshake", fmt.Sprintf("min=%7.2fms, med=%7.2fms, max=%7.2fms, avg=%7.2fms\n",
-		minTLS.Seconds()*1000, medianTLS.Seconds()*1000, maxTLS.Seconds()*1000, avgTLS.Seconds()*1000)))
-
-	fmt.Print(format("Time to First Byte", fmt.Sprintf("min=%7.2fms, med=%7.2fms, max=%7.2fms, avg=%7.2fms\n",
-		minTTFB.Seconds()*1000, medianTTFB.Seconds()*1000, maxTTFB.Seconds()*1000, avgTTFB.Seconds()*1000)))
-
-	fmt.Printf("Status Code Distribution:\n")
-	var sortedStatusCodes []int
-	for code := range statusCodes {
-		sortedStatusCodes = append(sortedStatusCodes, code)
-	}
-	sort.Ints(sortedStatusCodes)
-	for _, code := range sortedStatusCodes {
-		fmt.Printf("  %d: %d (%.2f%%)\n", code, statusCodes[code], float64(statusCodes[code])/float64(totalRequests)*100)
-	}
-	fmt.Println()
-	fmt.Println("Threshold Evaluation:")
-	m.evaluateThresholds(failureRate, minDuration, maxDuration, durations)
+	requests := make([]RequestMetrics, len(m.requests)-index)
+	copy(requests, m.requests[index:])
+	return requests
 }
 
-// calculateMetrics calculates the min, median, max, and avg values of a slice of durations
-func calculateMetrics(
-	durations []time.Duration,
-	total time.Duration,
-	count int64,
-) (min, median, max, avg time.Duration) {
-	if len(durations) == 0 {
-		return 0, 0, 0, 0
-	}
-	min = durations[0]
-	max = durations[len(durations)-1]
-	median = durations[len(durations)/2]
-	avg = total / time.Duration(count)
-	return
-}
-
-// estimateRequestSize estimates the size of an HTTP request
-func estimateRequestSize(req *http.Request) int64 {
-	size := int64(0)
-	size += int64(len(req.Method))
-	size += int64(len(req.URL.String()))
-	size += int64(len(req.Proto))
-	for name, values := range req.Header {
-		size += int64(len(name))
-		for _, value := range values {
-			size += int64(len(value))
-		}
-	}
-	if req.Body != nil {
-		body, _ := io.ReadAll(req.Body)
-		size += int64(len(body))
-		req.Body = io.NopCloser(bytes.NewBuffer(body))
-	}
-	return size
+// AddIteration records the duration of a completed iteration
+func (m *Metrics) AddIteration(duration time.Duration) {
+	m.mu.Lock()
+	m.iterations = append(m.iterations, duration)
+	m.mu.Unlock()
 }
 
-// estimateResponseSize estimates the size of an HTTP response
-func estimateResponseSize(resp *http.Response) int64 {
-	size := int64(0)
-	size += int64(len(resp.Status))
-	size += int64(len(resp.Proto))
-	for name, values := range resp.Header {
-		size += int64(len(name))
-		for _, value := range values {
-			size += int64(len(value))
-		}
+// AddCheck records the outcome of a named check
+func (m *Metrics) AddCheck(name string, pass bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	check, ok := m.checks[name]
+	if !ok {
+		check = &CheckSummary{Name: name}
+		m.checks[name] = check
+		m.checkOrder = append(m.checkOrder, name)
 	}
-	if resp.Body != nil {
-		body, _ := io.ReadAll(resp.Body)
-		size += int64(len(body))
-		resp.Body = io.NopCloser(bytes.NewBuffer(body))
+	if pass {
+		check.Passes++
+	} else {
+		check.Fails++
 	}
-	return size
 }
 
-// calculatePercentile calculates the value at a given percentile
-func calculatePercentile(
-	durations []time.Duration,
-	percentile int,
-) time.Duration {
-	index := int((float64(percentile) / 100) * float64(len(durations)-1))
-	return durations[index]
-}
-
-// evaluateThresholds evaluates the defined thresholds against the calculated metrics
-func (m *Metrics) evaluateThresholds(
-	failureRate float64,
-	minDuration, maxDuration time.Duration,
-	durations []time.Duration,
-) {
-	for key, conditions := range m.thresholds {
-		for _, condition := range conditions {
-			if key == "http_req_duration" {
-				var percentile int
-				var operator string
-				var threshold int
-				if _, err := fmt.Sscanf(condition, "p(%d) %s %d", &percentile, &operator, &threshold); err == nil {
-					value := calculatePercentile(durations, percentile)
-					m.evaluateCondition(fmt.Sprintf("http_req_duration p(%d)", percentile), value.Milliseconds(), operator, int64(threshold))
-				} else if _, err := fmt.Sscanf(condition, "min %s %d", &operator, &threshold); err == nil {
-					m.evaluateCondition("http_req_duration min", minDuration.Milliseconds(), operator, int64(threshold))
-				} else if _, err := fmt.Sscanf(condition, "max %s %d", &operator, &threshold); err == nil {
-					m.evaluateCondition("http_req_duration max", maxDuration.Milliseconds(), operator, int64(threshold))
-				}
-			} else if key == "http_req_failed" {
-				var operator string
-				var threshold float64
-				if _, err := fmt.Sscanf(condition, "rate%s%f", &operator, &threshold); err == nil {
-					m.evaluateCondition("http_req_failed rate", failureRate, operator, threshold)
-				}
-			}
-		}
-	}
+// RecordVUs records the current number of active virtual users
+func (m *Metrics) RecordVUs(vus int64) {
+	m.mu.Lock()
+	m.vuSamples = append(m.vuSamples, VUSample{Time: time.Now(), VUs: vus})
+	m.mu.Unlock()
 }
 
-// evaluateCondition evaluates a single condition against a metric
-func (m *Metrics) evaluateCondition(
-	metric string,
-	value interface{},
-	operator string,
-	threshold interface{},
-) {
-	pass := false
-	switch v := value.(type) {
-	case int64:
-		t := threshold.(int64)
-		switch operator {
-		case "<":
-			pass = v < t
-		case "<=":
-			pass = v <= t
-		case ">":
-			pass = v > t
-		case ">=":
-			pass = v >= t
-		case "==":
-			pass = v == t
-		}
-	case float64:
-		t := threshold.(float64)
-		switch operator {
-		case "<":
-			pass = v < t
-		case "<=":
-			pass = v <= t
-		case ">":
-			pass = v > t
-		case ">=":
-			pass = v >= t
-		case "==":
-			pass = v == t
-		}
-	}
-
-	if pass {
-		fmt.Printf("%s %s %v: PASS (value: %v)\n", metric, operator, threshold, value)
-	} else {
-		fmt.Printf("%s %s %v: FAIL (value: %v)\n", metric, operator, threshold, value)
-	}
+// CalculateAndDisplayMetrics calculates and displays the metrics
+func (m *Metrics) CalculateAndDisplayMetrics() *Summary {
+	summary := m.Summary()
+	summary.Display()
+	return summary
 }
@@ -17,7 +17,7 @@ type RoundTripper struct {
 
 // NewMetricsRoundTripper creates a new RoundTripper with metrics
 func (m *Metrics) NewMetricsRoundTripper(
-	transport *http.Transport,
+	transport http.RoundTripper,
 	metrics *Metrics,
 ) http.RoundTripper {
 	return &RoundTripper{
@@ -40,6 +40,7 @@ func (m *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		ConnectDone:          func(string, string, error) { metrics.ConnectDone = time.Now() },
 		TLSHandshakeStart:    func() { metrics.TLSHandshakeStart = time.Now() },
 		TLSHandshakeDone:     func(tls.ConnectionState, error) { metrics.TLSHandshakeDone = time.Now() },
+		GetConn:              func(string) { metrics.GetConn = time.Now() },
 		GotConn:              func(httptrace.GotConnInfo) { metrics.GotConn = time.Now() },
 		WroteHeaders:         func() { metrics.WroteHeaders = time.Now() },
 		WroteRequest:         func(httptrace.WroteRequestInfo) { metrics.WroteRequest = time.Now() },
@@ -51,16 +52,32 @@ func (m *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	resp, err := m.next.RoundTrip(req)
 
 	metrics.EndTime = time.Now()
+	// The response body is read while cloning, so ResponseDone marks the end of receiving it
 	if resp != nil {
 		metrics.Response = cloneResponse(resp)
 	}
+	metrics.ResponseDone = time.Now()
+	metrics.DataSent = estimateRequestSize(metrics.Request)
+	if metrics.Response != nil {
+		metrics.DataReceived = estimateResponseSize(metrics.Response)
+	}
 	metrics.Error = err
 
 	m.metrics.AddRequestMetrics(*metrics)
+	if observe := observerFrom(req.Context()); observe != nil {
+		observe(*metrics)
+	}
 
 	return resp, err
 }
 
+// CloseIdleConnections closes the idle connections of the wrapped transport, so http.Client.CloseIdleConnections reaches it
+func (m *RoundTripper) CloseIdleConnections() {
+	if closer, ok := m.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
 // cloneRequest clones an HTTP request
 func cloneRequest(req *http.Request) *http.Request {
 	clone := new(http.Request)
@@ -84,3 +101,42 @@ func cloneResponse(resp *http.Response) *http.Response {
 	}
 	return clone
 }
+
+// estimateRequestSize estimates the size of an HTTP request
+func estimateRequestSize(req *http.Request) int64 {
+	size := int64(0)
+	size += int64(len(req.Method))
+	size += int64(len(req.URL.String()))
+	size += int64(len(req.Proto))
+	for name, values := range req.Header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	if req.Body != nil {
+		body, _ := io.ReadAll(req.Body)
+		size += int64(len(body))
+		req.Body = io.NopCloser(bytes.NewBuffer(body))
+	}
+	return size
+}
+
+// estimateResponseSize estimates the size of an HTTP response
+func estimateResponseSize(resp *http.Response) int64 {
+	size := int64(0)
+	size += int64(len(resp.Status))
+	size += int64(len(resp.Proto))
+	for name, values := range resp.Header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	if resp.Body != nil {
+		body, _ := io.ReadAll(resp.Body)
+		size += int64(len(body))
+		resp.Body = io.NopCloser(bytes.NewBuffer(body))
+	}
+	return size
+}
@@ -0,0 +1,415 @@
+package metrics
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Summary is the structured result of a test run
+type Summary struct {
+	StartTime         time.Time
+	Duration          time.Duration
+	TotalRequests     int64
+	FailedRequests    int64
+	RPS               float64
+	Iterations        int64
+	IterationRate     float64
+	IterationDuration Trend
+	FailureRate       float64
+	DataSent          int64
+	DataReceived      int64
+	RequestDuration   Trend
+	Blocked           Trend
+	DNSLookup         Trend
+	TCPConnect        Trend
+	TLSHandshake      Trend
+	TimeToFirstByte   Trend
+	StatusCodes       map[int]int
+	Endpoints         []EndpointSummary
+	Timeline          []TimelinePoint
+	Checks            []CheckSummary
+	ThresholdResults  []ThresholdResult
+}
+
+// Trend holds the distribution of a duration metric
+type Trend struct {
+	Min, Median, Max, Avg time.Duration
+	P90, P95, P99         time.Duration
+}
+
+// EndpointSummary holds the results of all requests to a single method and URL
+type EndpointSummary struct {
+	Method   string
+	URL      string
+	Requests int64
+	Failed   int64
+	Duration Trend
+}
+
+// TimelinePoint holds the results of a single second of the test run
+type TimelinePoint struct {
+	Offset      time.Duration
+	VUs         int64
+	Requests    int64
+	Failed      int64
+	AvgDuration time.Duration
+	P95Duration time.Duration
+}
+
+// CheckSummary holds the number of passed and failed evaluations of a check
+type CheckSummary struct {
+	Name   string
+	Passes int64
+	Fails  int64
+}
+
+// ThresholdResult holds the outcome of a single threshold condition
+type ThresholdResult struct {
+	Metric    string
+	Operator  string
+	Threshold interface{}
+	Value     interface{}
+	Pass      bool
+	// Error describes why the condition could not be evaluated
+	Error string
+}
+
+// Passed reports whether every threshold passed
+func (s *Summary) Passed() bool {
+	for _, result := range s.ThresholdResults {
+		if !result.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary calculates the structured summary of the metrics recorded so far
+func (m *Metrics) Summary() *Summary {
+	m.mu.Lock()
+	totalRequests := int64(len(m.requests))
+	requests := make([]RequestMetrics, totalRequests)
+	copy(requests, m.requests)
+	checks := make([]CheckSummary, 0, len(m.checkOrder))
+	for _, name := range m.checkOrder {
+		checks = append(checks, *m.checks[name])
+	}
+	vuSamples := make([]VUSample, len(m.vuSamples))
+	copy(vuSamples, m.vuSamples)
+	iterations := make([]time.Duration, len(m.iterations))
+	copy(iterations, m.iterations)
+	m.mu.Unlock()
+
+	totalDuration := time.Since(m.startTime)
+	summary := &Summary{
+		StartTime:     m.startTime,
+		Duration:      totalDuration,
+		TotalRequests: totalRequests,
+		RPS:           float64(totalRequests) / totalDuration.Seconds(),
+		Iterations:    int64(len(iterations)),
+		IterationRate: float64(len(iterations)) / totalDuration.Seconds(),
+		StatusCodes:   make(map[int]int),
+		Checks:        checks,
+	}
+
+	var totalReqDuration, totalBlocked, totalDNS, totalConnect, totalTLS, totalTTFB time.Duration
+	durations := make([]time.Duration, 0, totalRequests)
+	blockedDurations := make([]time.Duration, 0, totalRequests)
+	dnsDurations := make([]time.Duration, 0, totalRequests)
+	connectDurations := make([]time.Duration, 0, totalRequests)
+	tlsDurations := make([]time.Duration, 0, totalRequests)
+	ttfbDurations := make([]time.Duration, 0, totalRequests)
+
+	for _, req := range requests {
+		duration := req.Duration()
+		durations = append(durations, duration)
+		totalReqDuration += duration
+
+		if req.Failed() {
+			summary.FailedRequests++
+		}
+
+		if req.Response != nil {
+			summary.StatusCodes[req.Response.StatusCode]++
+		}
+
+		summary.DataSent += req.DataSent
+		summary.DataReceived += req.DataReceived
+
+		if !req.GetConn.IsZero() {
+			blocked := req.Timings().Blocked
+			blockedDurations = append(blockedDurations, blocked)
+			totalBlocked += blocked
+		}
+		if !req.DNSStart.IsZero() && !req.DNSDone.IsZero() {
+			dnsDuration := req.DNSDone.Sub(req.DNSStart)
+			dnsDurations = append(dnsDurations, dnsDuration)
+			totalDNS += dnsDuration
+		}
+		if !req.ConnectStart.IsZero() && !req.ConnectDone.IsZero() {
+			connectDuration := req.ConnectDone.Sub(req.ConnectStart)
+			connectDurations = append(connectDurations, connectDuration)
+			totalConnect += connectDuration
+		}
+		if !req.TLSHandshakeStart.IsZero() && !req.TLSHandshakeDone.IsZero() {
+			tlsDuration := req.TLSHandshakeDone.Sub(req.TLSHandshakeStart)
+			tlsDurations = append(tlsDurations, tlsDuration)
+			totalTLS += tlsDuration
+		}
+		if !req.WroteRequest.IsZero() && !req.GotFirstResponseByte.IsZero() {
+			ttfbDuration := req.GotFirstResponseByte.Sub(req.WroteRequest)
+			ttfbDurations = append(ttfbDurations, ttfbDuration)
+			totalTTFB += ttfbDuration
+		}
+	}
+
+	summary.RequestDuration = calculateTrend(durations, totalReqDuration, totalRequests)
+	// Only requests that waited for a connection have a blocked sample, so they alone make up the average
+	summary.Blocked = calculateTrend(blockedDurations, totalBlocked, int64(len(blockedDurations)))
+	summary.DNSLookup = calculateTrend(dnsDurations, totalDNS, totalRequests)
+	summary.TCPConnect = calculateTrend(connectDurations, totalConnect, totalRequests)
+	summary.TLSHandshake = calculateTrend(tlsDurations, totalTLS, totalRequests)
+	summary.TimeToFirstByte = calculateTrend(ttfbDurations, totalTTFB, totalRequests)
+
+	if totalRequests > 0 {
+		summary.FailureRate = float64(summary.FailedRequests) / float64(totalRequests)
+	}
+
+	var totalIterationDuration time.Duration
+	for _, iteration := range iterations {
+		totalIterationDuration += iteration
+	}
+	summary.IterationDuration = calculateTrend(iterations, totalIterationDuration, summary.Iterations)
+
+	summary.Endpoints = calculateEndpoints(requests)
+	summary.Timeline = calculateTimeline(requests, vuSamples, m.startTime, totalDuration)
+	summary.ThresholdResults = m.evaluateThresholds(summary.FailureRate, map[string]trendValues{
+		"http_req_duration": {trend: summary.RequestDuration, values: durations},
+		"http_req_blocked":  {trend: summary.Blocked, values: blockedDurations},
+	})
+
+	return summary
+}
+
+// Display prints the summary to stdout
+func (s *Summary) Display() {
+	convertBytes := func(bytes int64) string {
+		kb := float64(bytes) / 1024
+		mb := kb / 1024
+		if mb >= 1 {
+			return fmt.Sprintf("%.2f MB", mb)
+		} else if kb >= 1 {
+			return fmt.Sprintf("%.2f KB", kb)
+		}
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+
+	dataRateSent := int64(float64(s.DataSent) / s.Duration.Seconds())
+	dataRateReceived := int64(float64(s.DataReceived) / s.Duration.Seconds())
+
+	format := func(label string, value interface{}) string {
+		return fmt.Sprintf("%-*s: %-*v", 25, label, 20, value)
+	}
+	formatTrend := func(trend Trend) string {
+		return fmt.Sprintf("min=%7.2fms, med=%7.2fms, max=%7.2fms, avg=%7.2fms\n",
+			trend.Min.Seconds()*1000, trend.Median.Seconds()*1000, trend.Max.Seconds()*1000, trend.Avg.Seconds()*1000)
+	}
+
+	fmt.Print(format("Iterations", fmt.Sprintf("%d (%.2f/s)\n", s.Iterations, s.IterationRate)))
+	fmt.Print(format("Iteration Duration", formatTrend(s.IterationDuration)))
+	fmt.Print(format("Total Requests", fmt.Sprintf("%d (%.2f/s)\n", s.TotalRequests, s.RPS)))
+	fmt.Print(format("Data Sent", fmt.Sprintf("%s (%s/s)\n", convertBytes(s.DataSent), convertBytes(dataRateSent))))
+	fmt.Print(format("Data Received", fmt.Sprintf("%s (%s/s)\n", convertBytes(s.DataReceived), convertBytes(dataRateReceived))))
+
+	fmt.Print(format("HTTP Request Duration", formatTrend(s.RequestDuration)))
+
+	fmt.Print(format("Percentiles", fmt.Sprintf("90th=%7.2fms, 95th=%7.2fms, 99th=%7.2fms\n",
+		s.RequestDuration.P90.Seconds()*1000, s.RequestDuration.P95.Seconds()*1000, s.RequestDuration.P99.Seconds()*1000)))
+
+	fmt.Print(format("Blocked", formatTrend(s.Blocked)))
+	fmt.Print(format("DNS Lookup", formatTrend(s.DNSLookup)))
+	fmt.Print(format("TCP Connect", formatTrend(s.TCPConnect)))
+	fmt.Print(format("TLS Handshake", formatTrend(s.TLSHandshake)))
+	fmt.Print(format("Time to First Byte", formatTrend(s.TimeToFirstByte)))
+
+	fmt.Printf("Status Code Distribution:\n")
+	var sortedStatusCodes []int
+	for code := range s.StatusCodes {
+		sortedStatusCodes = append(sortedStatusCodes, code)
+	}
+	sort.Ints(sortedStatusCodes)
+	for _, code := range sortedStatusCodes {
+		fmt.Printf("  %d: %d (%.2f%%)\n", code, s.StatusCodes[code], float64(s.StatusCodes[code])/float64(s.TotalRequests)*100)
+	}
+
+	if len(s.Checks) > 0 {
+		fmt.Println()
+		fmt.Println("Checks:")
+		for _, check := range s.Checks {
+			fmt.Printf("  %s: %d passed, %d failed\n", check.Name, check.Passes, check.Fails)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Threshold Evaluation:")
+	for _, result := range s.ThresholdResults {
+		verdict := "PASS"
+		if !result.Pass {
+			verdict = "FAIL"
+		}
+		if result.Error != "" {
+			fmt.Printf("%s %s: %s (%s)\n", result.Metric, result.Operator, verdict, result.Error)
+			continue
+		}
+		fmt.Printf("%s %s %v: %s (value: %v)\n", result.Metric, result.Operator, result.Threshold, verdict, result.Value)
+	}
+}
+
+// calculateTrend sorts the durations and calculates their distribution
+func calculateTrend(
+	durations []time.Duration,
+	total time.Duration,
+	count int64,
+) Trend {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	min, median, max, avg := calculateMetrics(durations, total, count)
+	return Trend{
+		Min:    min,
+		Median: median,
+		Max:    max,
+		Avg:    avg,
+		P90:    calculatePercentile(durations, 90),
+		P95:    calculatePercentile(durations, 95),
+		P99:    calculatePercentile(durations, 99),
+	}
+}
+
+// calculateMetrics calculates the min, median, max, and avg values of a sorted slice of durations
+func calculateMetrics(
+	durations []time.Duration,
+	total time.Duration,
+	count int64,
+) (min, median, max, avg time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0, 0, 0
+	}
+	min = durations[0]
+	max = durations[len(durations)-1]
+	median = durations[len(durations)/2]
+	avg = total / time.Duration(count)
+	return
+}
+
+// calculatePercentile calculates the value at a given percentile of a sorted slice of durations
+func calculatePercentile(
+	durations []time.Duration,
+	percentile int,
+) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	index := int((float64(percentile) / 100) * float64(len(durations)-1))
+	return durations[index]
+}
+
+// calculateEndpoints groups the requests by method and URL
+func calculateEndpoints(requests []RequestMetrics) []EndpointSummary {
+	type endpointKey struct{ method, url string }
+	type endpoint struct {
+		summary   EndpointSummary
+		total     time.Duration
+		durations []time.Duration
+	}
+
+	endpoints := make(map[endpointKey]*endpoint)
+	var order []endpointKey
+	for _, req := range requests {
+		if req.Request == nil {
+			continue
+		}
+		key := endpointKey{method: req.Request.Method, url: EndpointURL(req.Request.URL)}
+		e, ok := endpoints[key]
+		if !ok {
+			e = &endpoint{summary: EndpointSummary{Method: key.method, URL: key.url}}
+			endpoints[key] = e
+			order = append(order, key)
+		}
+		e.summary.Requests++
+		if req.Failed() {
+			e.summary.Failed++
+		}
+		e.total += req.Duration()
+		e.durations = append(e.durations, req.Duration())
+	}
+
+	summaries := make([]EndpointSummary, 0, len(order))
+	for _, key := range order {
+		e := endpoints[key]
+		e.summary.Duration = calculateTrend(e.durations, e.total, e.summary.Requests)
+		summaries = append(summaries, e.summary)
+	}
+	return summaries
+}
+
+// EndpointURL returns the URL without credentials, query string and fragment, identifying the endpoint of a request
+func EndpointURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	stripped := *u
+	stripped.RawQuery = ""
+	stripped.Fragment = ""
+	stripped.User = nil
+	return stripped.String()
+}
+
+// calculateTimeline buckets the requests and VU samples into one point per second
+func calculateTimeline(
+	requests []RequestMetrics,
+	vuSamples []VUSample,
+	startTime time.Time,
+	totalDuration time.Duration,
+) []TimelinePoint {
+	seconds := int(totalDuration.Seconds()) + 1
+	points := make([]TimelinePoint, seconds)
+	durations := make([][]time.Duration, seconds)
+	totals := make([]time.Duration, seconds)
+
+	bucket := func(t time.Time) int {
+		i := int(t.Sub(startTime).Seconds())
+		if i < 0 {
+			return 0
+		}
+		if i >= seconds {
+			return seconds - 1
+		}
+		return i
+	}
+
+	for i := range points {
+		points[i].Offset = time.Duration(i) * time.Second
+	}
+	for _, sample := range vuSamples {
+		points[bucket(sample.Time)].VUs = sample.VUs
+	}
+	for _, req := range requests {
+		i := bucket(req.StartTime)
+		points[i].Requests++
+		if req.Failed() {
+			points[i].Failed++
+		}
+		totals[i] += req.Duration()
+		durations[i] = append(durations[i], req.Duration())
+	}
+	for i := range points {
+		if points[i].Requests == 0 {
+			continue
+		}
+		trend := calculateTrend(durations[i], totals[i], points[i].Requests)
+		points[i].AvgDuration = trend.Avg
+		points[i].P95Duration = trend.P95
+	}
+	return points
+}
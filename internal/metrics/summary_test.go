@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummaryBlockedAveragesOnlyBlockedSamples(t *testing.T) {
+	m := NewMetrics(nil)
+	start := time.Now()
+	for _, blocked := range []time.Duration{2 * time.Millisecond, 4 * time.Millisecond} {
+		m.AddRequestMetrics(RequestMetrics{
+			StartTime: start,
+			GetConn:   start,
+			GotConn:   start.Add(blocked),
+			EndTime:   start.Add(10 * time.Millisecond),
+		})
+	}
+	// A request without connection hooks, e.g. one that failed before asking for a connection
+	m.AddRequestMetrics(RequestMetrics{StartTime: start, EndTime: start.Add(time.Millisecond)})
+
+	summary := m.Summary()
+	if summary.Blocked.Avg != 3*time.Millisecond {
+		t.Errorf("blocked avg = %v, want 3ms", summary.Blocked.Avg)
+	}
+	if summary.Blocked.Min != 2*time.Millisecond || summary.Blocked.Max != 4*time.Millisecond {
+		t.Errorf("blocked min/max = %v/%v, want 2ms/4ms", summary.Blocked.Min, summary.Blocked.Max)
+	}
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// thresholdConditionPattern matches conditions such as p(95) < 200, max <= 500 or rate < 0.01
+var thresholdConditionPattern = regexp.MustCompile(`^\s*(p\((\d+)\)|min|max|rate)\s*(<=|>=|==|<|>)\s*(-?\d+(?:\.\d+)?)\s*$`)
+
+// trendMetrics are the duration metrics accepting p(N), min and max conditions in milliseconds
+var trendMetrics = map[string]bool{
+	"http_req_duration": true,
+	"http_req_blocked":  true,
+}
+
+// thresholdCondition is a parsed threshold condition
+type thresholdCondition struct {
+	// aggregation is p, min, max or rate
+	aggregation string
+	percentile  int
+	operator    string
+	threshold   float64
+}
+
+// ValidateThresholds checks that every threshold targets a supported metric with a condition it accepts
+func ValidateThresholds(thresholds map[string][]string) error {
+	for metric, conditions := range thresholds {
+		for _, condition := range conditions {
+			if _, err := parseCondition(metric, condition); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseCondition parses a threshold condition, checking that the metric supports it
+func parseCondition(
+	metric string,
+	condition string,
+) (thresholdCondition, error) {
+	match := thresholdConditionPattern.FindStringSubmatch(condition)
+	if match == nil {
+		return thresholdCondition{}, fmt.Errorf("invalid threshold %q for %s, expected e.g. p(95) < 200 or rate < 0.01", condition, metric)
+	}
+
+	parsed := thresholdCondition{aggregation: match[1], operator: match[3]}
+	if match[2] != "" {
+		parsed.aggregation = "p"
+		parsed.percentile, _ = strconv.Atoi(match[2])
+		if parsed.percentile > 100 {
+			return thresholdCondition{}, fmt.Errorf("invalid threshold %q for %s: percentile must be between 0 and 100", condition, metric)
+		}
+	}
+	parsed.threshold, _ = strconv.ParseFloat(match[4], 64)
+
+	switch {
+	case trendMetrics[metric]:
+		if parsed.aggregation == "rate" {
+			return thresholdCondition{}, fmt.Errorf("invalid threshold %q: %s supports p(N), min and max", condition, metric)
+		}
+		if parsed.threshold != math.Trunc(parsed.threshold) {
+			return thresholdCondition{}, fmt.Errorf("invalid threshold %q for %s: expected whole milliseconds", condition, metric)
+		}
+	case metric == "http_req_failed":
+		if parsed.aggregation != "rate" {
+			return thresholdCondition{}, fmt.Errorf("invalid threshold %q: http_req_failed supports rate", condition)
+		}
+	default:
+		return thresholdCondition{}, fmt.Errorf("unsupported threshold metric %q, expected http_req_duration, http_req_blocked or http_req_failed", metric)
+	}
+	return parsed, nil
+}
+
+// evaluateThresholds evaluates the defined thresholds against the calculated metrics.
+// Conditions that cannot be evaluated fail, so they are never silently left out of the results.
+func (m *Metrics) evaluateThresholds(
+	failureRate float64,
+	trends map[string]trendValues,
+) []ThresholdResult {
+	keys := make([]string, 0, len(m.thresholds))
+	for key := range m.thresholds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var results []ThresholdResult
+	for _, key := range keys {
+		for _, condition := range m.thresholds[key] {
+			parsed, err := parseCondition(key, condition)
+			if err != nil {
+				results = append(results, ThresholdResult{Metric: key, Operator: condition, Threshold: "", Error: err.Error()})
+				continue
+			}
+			if parsed.aggregation == "rate" {
+				results = append(results, evaluateCondition(key+" rate", failureRate, parsed.operator, parsed.threshold))
+			} else {
+				results = append(results, evaluateTrendCondition(key, parsed, trends[key]))
+			}
+		}
+	}
+	return results
+}
+
+// trendValues holds a trend metric and the sorted values it was calculated from
+type trendValues struct {
+	trend  Trend
+	values []time.Duration
+}
+
+// evaluateTrendCondition evaluates a p(N), min or max condition in milliseconds against a trend metric
+func evaluateTrendCondition(
+	metric string,
+	condition thresholdCondition,
+	trend trendValues,
+) ThresholdResult {
+	threshold := int64(condition.threshold)
+	switch condition.aggregation {
+	case "p":
+		value := calculatePercentile(trend.values, condition.percentile)
+		return evaluateCondition(fmt.Sprintf("%s p(%d)", metric, condition.percentile), value.Milliseconds(), condition.operator, threshold)
+	case "min":
+		return evaluateCondition(metric+" min", trend.trend.Min.Milliseconds(), condition.operator, threshold)
+	default:
+		return evaluateCondition(metric+" max", trend.trend.Max.Milliseconds(), condition.operator, threshold)
+	}
+}
+
+// evaluateCondition evaluates a single condition against a metric
+func evaluateCondition(
+	metric string,
+	value interface{},
+	operator string,
+	threshold interface{},
+) ThresholdResult {
+	pass := false
+	switch v := value.(type) {
+	case int64:
+		t := threshold.(int64)
+		switch operator {
+		case "<":
+			pass = v < t
+		case "<=":
+			pass = v <= t
+		case ">":
+			pass = v > t
+		case ">=":
+			pass = v >= t
+		case "==":
+			pass = v == t
+		}
+	case float64:
+		t := threshold.(float64)
+		switch operator {
+		case "<":
+			pass = v < t
+		case "<=":
+			pass = v <= t
+		case ">":
+			pass = v > t
+		case ">=":
+			pass = v >= t
+		case "==":
+			pass = v == t
+		}
+	}
+
+	return ThresholdResult{
+		Metric:    metric,
+		Operator:  operator,
+		Threshold: threshold,
+		Value:     value,
+		Pass:      pass,
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCondition(t *testing.T) {
+	for _, tc := range []struct {
+		metric    string
+		condition string
+		want      thresholdCondition
+		valid     bool
+	}{
+		{"http_req_duration", "p(95) < 200", thresholdCondition{aggregation: "p", percentile: 95, operator: "<", threshold: 200}, true},
+		{"http_req_duration", "max<=500", thresholdCondition{aggregation: "max", operator: "<=", threshold: 500}, true},
+		{"http_req_blocked", "min >= 0", thresholdCondition{aggregation: "min", operator: ">=", threshold: 0}, true},
+		{"http_req_failed", "rate < 0.01", thresholdCondition{aggregation: "rate", operator: "<", threshold: 0.01}, true},
+		{"http_req_duration", "p(95) << 200", thresholdCondition{}, false},
+		{"http_req_duration", "p(95) < 200ms", thresholdCondition{}, false},
+		{"http_req_duration", "p(95) < 200.5", thresholdCondition{}, false},
+		{"http_req_duration", "p(101) < 200", thresholdCondition{}, false},
+		{"http_req_duration", "rate < 0.01", thresholdCondition{}, false},
+		{"http_req_failed", "p(95) < 200", thresholdCondition{}, false},
+		{"http_req_waiting", "p(95) < 200", thresholdCondition{}, false},
+	} {
+		got, err := parseCondition(tc.metric, tc.condition)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s %q: got error %v, want valid %v", tc.metric, tc.condition, err, tc.valid)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s %q: got %+v, want %+v", tc.metric, tc.condition, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	m := NewMetrics(map[string][]string{
+		"http_req_duration": {"p(50) < 100", "max < 100"},
+		"http_req_failed":   {"rate < 0.5"},
+		"http_req_waiting":  {"p(95) < 200"},
+	})
+	values := []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 150 * time.Millisecond}
+	results := m.evaluateThresholds(0.25, map[string]trendValues{
+		"http_req_duration": {trend: Trend{Min: values[0], Max: values[2]}, values: values},
+	})
+
+	want := []struct {
+		metric string
+		pass   bool
+		failed bool
+	}{
+		{"http_req_duration p(50)", true, false},
+		{"http_req_duration max", false, false},
+		{"http_req_failed rate", true, false},
+		{"http_req_waiting", false, true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		if results[i].Metric != w.metric || results[i].Pass != w.pass || (results[i].Error != "") != w.failed {
+			t.Errorf("result %d = %+v, want metric %s pass %v invalid %v", i, results[i], w.metric, w.pass, w.failed)
+		}
+	}
+}
+
+func TestValidateThresholds(t *testing.T) {
+	if err := ValidateThresholds(map[string][]string{"http_req_duration": {"p(95) < 200"}, "http_req_failed": {"rate < 0.01"}}); err != nil {
+		t.Errorf("valid thresholds rejected: %v", err)
+	}
+	if err := ValidateThresholds(map[string][]string{"http_req_duration": {"p95 < 200"}}); err == nil {
+		t.Error("malformed condition accepted")
+	}
+	if err := ValidateThresholds(map[string][]string{"iterations": {"rate > 1"}}); err == nil {
+		t.Error("unsupported metric accepted")
+	}
+}
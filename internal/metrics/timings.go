@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// Timings breaks the duration of a request down into its phases
+type Timings struct {
+	// Blocked is the time spent waiting for a free connection before dialing or reusing one
+	Blocked time.Duration
+	// Connecting is the time spent establishing the TCP connection
+	Connecting time.Duration
+	// TLS is the time spent on the TLS handshake
+	TLS time.Duration
+	// Sending is the time spent writing the request
+	Sending time.Duration
+	// Waiting is the time between writing the request and receiving the first response byte
+	Waiting time.Duration
+	// Receiving is the time spent reading the response
+	Receiving time.Duration
+	// Duration is the total of Sending, Waiting and Receiving
+	Duration time.Duration
+}
+
+// Timings returns the phases of the request, leaving out the ones that did not happen
+func (r RequestMetrics) Timings() Timings {
+	var t Timings
+
+	if !r.GetConn.IsZero() {
+		blockedEnd := r.GotConn
+		for _, next := range []time.Time{r.DNSStart, r.ConnectStart} {
+			if !next.IsZero() && (blockedEnd.IsZero() || next.Before(blockedEnd)) {
+				blockedEnd = next
+			}
+		}
+		t.Blocked = between(r.GetConn, blockedEnd)
+	}
+	t.Connecting = between(r.ConnectStart, r.ConnectDone)
+	t.TLS = between(r.TLSHandshakeStart, r.TLSHandshakeDone)
+	t.Sending = between(r.GotConn, r.WroteRequest)
+	t.Waiting = between(r.WroteRequest, r.GotFirstResponseByte)
+	t.Receiving = between(r.GotFirstResponseByte, r.ResponseDone)
+	t.Duration = t.Sending + t.Waiting + t.Receiving
+	return t
+}
+
+// between returns the time from start to end, or zero when either did not happen
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+type observerKey struct{}
+
+// WithObserver returns a context whose requests are passed to observe once their metrics are recorded
+func WithObserver(
+	ctx context.Context,
+	observe func(RequestMetrics),
+) context.Context {
+	return context.WithValue(ctx, observerKey{}, observe)
+}
+
+// observerFrom returns the observer attached to the context, if any
+func observerFrom(ctx context.Context) func(RequestMetrics) {
+	observe, _ := ctx.Value(observerKey{}).(func(RequestMetrics))
+	return observe
+}
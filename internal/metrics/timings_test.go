@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingsReceivingEndsWhenTheBodyIsRead(t *testing.T) {
+	start := time.Now()
+	r := RequestMetrics{
+		StartTime:            start,
+		GetConn:              start,
+		GotConn:              start.Add(time.Millisecond),
+		WroteRequest:         start.Add(2 * time.Millisecond),
+		GotFirstResponseByte: start.Add(10 * time.Millisecond),
+		EndTime:              start.Add(10 * time.Millisecond),
+		ResponseDone:         start.Add(15 * time.Millisecond),
+	}
+
+	timings := r.Timings()
+	if timings.Blocked != time.Millisecond || timings.Sending != time.Millisecond || timings.Waiting != 8*time.Millisecond {
+		t.Errorf("unexpected timings %+v", timings)
+	}
+	if timings.Receiving != 5*time.Millisecond {
+		t.Errorf("receiving = %v, want 5ms", timings.Receiving)
+	}
+	if r.Duration() != 10*time.Millisecond {
+		t.Errorf("duration = %v, want 10ms up to the response headers", r.Duration())
+	}
+}
+
+func TestMilliseconds(t *testing.T) {
+	if got := Milliseconds(1500 * time.Microsecond); got != 1.5 {
+		t.Errorf("Milliseconds = %v, want 1.5", got)
+	}
+}
@@ -1,6 +1,18 @@
 package models
 
 type Options struct {
-	Thresholds map[string][]string `json:"thresholds"`
-	Stages     []Stage             `json:"stages"`
+	Thresholds           map[string][]string `json:"thresholds"`
+	Stages               []Stage             `json:"stages"`
+	Outputs              OutputOptions       `json:"outputs"`
+	ThinkTime            *ThinkTime          `json:"thinkTime,omitempty"`
+	MinIterationDuration string              `json:"minIterationDuration,omitempty"`
+	NoCookiesReset       bool                `json:"noCookiesReset,omitempty"`
+	NoConnectionReuse    bool                `json:"noConnectionReuse,omitempty"`
+	NoVUConnectionReuse  bool                `json:"noVUConnectionReuse,omitempty"`
+	Batch                int                 `json:"batch,omitempty"`
+	BatchPerHost         int                 `json:"batchPerHost,omitempty"`
+	TLS                  *TLSOptions         `json:"tls,omitempty"`
+	HTTPVersion          string              `json:"httpVersion,omitempty"`
+	Hosts                map[string]string   `json:"hosts,omitempty"`
+	DNS                  *DNSOptions         `json:"dns,omitempty"`
 }
@@ -0,0 +1,33 @@
+package models
+
+// OutputOptions holds the settings for each metrics output enabled with --out
+type OutputOptions struct {
+	PrometheusRW PrometheusRWOptions `json:"prometheusRW"`
+	OTLP         OTLPOptions         `json:"otlp"`
+	StatsD       StatsDOptions       `json:"statsd"`
+}
+
+// PrometheusRWOptions configures the Prometheus remote-write output
+type PrometheusRWOptions struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	// Retries is how often a failed push is retried, nil uses the default and 0 disables retries
+	Retries       *int   `json:"retries,omitempty"`
+	FlushInterval string `json:"flushInterval,omitempty"`
+}
+
+// OTLPOptions configures the OpenTelemetry OTLP/HTTP output
+type OTLPOptions struct {
+	ServiceName   string            `json:"serviceName,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	FlushInterval string            `json:"flushInterval,omitempty"`
+	Traces        bool              `json:"traces,omitempty"`
+}
+
+// StatsDOptions configures the StatsD/DogStatsD UDP output
+type StatsDOptions struct {
+	Namespace     string   `json:"namespace,omitempty"`
+	EnableTags    bool     `json:"enableTags,omitempty"`
+	TagBlocklist  []string `json:"tagBlocklist,omitempty"`
+	FlushInterval string   `json:"flushInterval,omitempty"`
+	BufferSize    int      `json:"bufferSize,omitempty"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ThinkTimeFixed    = "fixed"
+	ThinkTimeUniform  = "uniform"
+	ThinkTimeGaussian = "gaussian"
+)
+
+// ThinkTime describes the pause applied between the iterations of a virtual user
+type ThinkTime struct {
+	Type     string `json:"type"`
+	Duration string `json:"duration,omitempty"`
+	Min      string `json:"min,omitempty"`
+	Max      string `json:"max,omitempty"`
+	Mean     string `json:"mean,omitempty"`
+	StdDev   string `json:"stdDev,omitempty"`
+}
+
+// ThinkTimeDurations holds the parsed durations of a ThinkTime
+type ThinkTimeDurations struct {
+	Duration, Min, Max, Mean, StdDev time.Duration
+}
+
+// GetDurations parses the durations required by the think time distribution
+func (t *ThinkTime) GetDurations() (ThinkTimeDurations, error) {
+	var durations ThinkTimeDurations
+	parse := func(name, value string, target *time.Duration) error {
+		if value == "" {
+			return fmt.Errorf("%s think time requires %s", t.Type, name)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid think time %s: %w", name, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("think time %s cannot be negative", name)
+		}
+		*target = d
+		return nil
+	}
+
+	switch t.Type {
+	case ThinkTimeFixed:
+		if err := parse("duration", t.Duration, &durations.Duration); err != nil {
+			return durations, err
+		}
+	case ThinkTimeUniform:
+		if err := parse("min", t.Min, &durations.Min); err != nil {
+			return durations, err
+		}
+		if err := parse("max", t.Max, &durations.Max); err != nil {
+			return durations, err
+		}
+		if durations.Max < durations.Min {
+			return durations, fmt.Errorf("think time max cannot be less than min")
+		}
+	case ThinkTimeGaussian:
+		if err := parse("mean", t.Mean, &durations.Mean); err != nil {
+			return durations, err
+		}
+		if err := parse("stdDev", t.StdDev, &durations.StdDev); err != nil {
+			return durations, err
+		}
+	default:
+		return durations, fmt.Errorf("unknown think time type %q", t.Type)
+	}
+	return durations, nil
+}
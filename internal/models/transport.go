@@ -0,0 +1,54 @@
+package models
+
+const (
+	// HTTPVersion1 forces HTTP/1.1
+	HTTPVersion1 = "1.1"
+	// HTTPVersion2 forces HTTP/2 over TLS
+	HTTPVersion2 = "2"
+	// HTTPVersionH2C forces HTTP/2 over cleartext connections
+	HTTPVersionH2C = "h2c"
+)
+
+// TLSOptions configures the TLS connections of the HTTP client
+type TLSOptions struct {
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// MinVersion and MaxVersion are one of tls1.0, tls1.1, tls1.2 or tls1.3
+	MinVersion string `json:"minVersion,omitempty"`
+	MaxVersion string `json:"maxVersion,omitempty"`
+	// CipherSuites lists cipher suites by their Go names, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+	// CACerts holds PEM encoded certificates trusted instead of the system roots
+	CACerts     string       `json:"caCerts,omitempty"`
+	ClientCerts []ClientCert `json:"clientCerts,omitempty"`
+}
+
+// ClientCert is a PEM encoded client certificate presented to the listed domains
+type ClientCert struct {
+	// Domains lists host names, optionally with a leading wildcard such as *.example.com
+	Domains []string `json:"domains"`
+	Cert    string   `json:"cert"`
+	Key     string   `json:"key"`
+}
+
+const (
+	// DNSSelectFirst always connects to the first resolved address
+	DNSSelectFirst = "first"
+	// DNSSelectRandom connects to a random resolved address
+	DNSSelectRandom = "random"
+	// DNSSelectRoundRobin cycles through the resolved addresses of a host
+	DNSSelectRoundRobin = "roundRobin"
+	// DNSPolicyPreferIPv4 tries IPv4 addresses before IPv6 ones
+	DNSPolicyPreferIPv4 = "preferIPv4"
+	// DNSPolicyPreferIPv6 tries IPv6 addresses before IPv4 ones
+	DNSPolicyPreferIPv6 = "preferIPv6"
+)
+
+// DNSOptions configures how the HTTP client resolves host names
+type DNSOptions struct {
+	// TTL is how long resolved addresses are cached, e.g. 5m; empty or 0 resolves every new connection
+	TTL string `json:"ttl,omitempty"`
+	// Select is first, random or roundRobin, first by default
+	Select string `json:"select,omitempty"`
+	// Policy is preferIPv4 or preferIPv6, keeping the order of the resolver when empty
+	Policy string `json:"policy,omitempty"`
+}
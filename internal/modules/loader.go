@@ -0,0 +1,126 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// Loader transpiles and compiles script files, caching the compiled programs, data files and shared arrays
+// so every runtime shares them
+type Loader struct {
+	mu       sync.Mutex
+	programs map[string]*goja.Program
+	files    map[string][]byte
+	// sharedArrays holds the data of every SharedArray by name
+	sharedArrays map[string]*sharedData
+	builtins     map[string]Builtin
+}
+
+// Builtin creates the exports of a built-in module for a runtime, dir being the directory of the requiring module
+type Builtin func(vm *goja.Runtime, dir string) (goja.Value, error)
+
+// NewLoader creates a new Loader
+func NewLoader() *Loader {
+	return &Loader{
+		programs:     make(map[string]*goja.Program),
+		files:        make(map[string][]byte),
+		sharedArrays: make(map[string]*sharedData),
+		builtins:     make(map[string]Builtin),
+	}
+}
+
+// RegisterBuiltin makes the built-in module available to require and import under name
+func (l *Loader) RegisterBuiltin(
+	name string,
+	builtin Builtin,
+) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.builtins[name] = builtin
+}
+
+// builtin returns the built-in module registered under name
+func (l *Loader) builtin(name string) (Builtin, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	builtin, ok := l.builtins[name]
+	return builtin, ok
+}
+
+// Program returns the compiled program of the file at the absolute path.
+// Running the program yields a function taking (exports, require, module, __filename, __dirname, open).
+func (l *Loader) Program(path string) (*goja.Program, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if program, ok := l.programs[path]; ok {
+		return program, nil
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading module %s: %w", path, err)
+	}
+
+	code, err := transpile(path, string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := goja.Compile(path, wrap(code), false)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling module: %w", err)
+	}
+
+	l.programs[path] = program
+	return program, nil
+}
+
+// transpile strips TypeScript types, converts ES module syntax to CommonJS and attaches an inline source map,
+// so errors point at the original file and line
+func transpile(path, source string) (string, error) {
+	loader := api.LoaderJS
+	if isTypeScript(path) {
+		loader = api.LoaderTS
+	}
+
+	result := api.Transform(source, api.TransformOptions{
+		Loader:     loader,
+		Format:     api.FormatCommonJS,
+		Target:     api.ES2017,
+		Sourcefile: path,
+		Sourcemap:  api.SourceMapInline,
+	})
+	if len(result.Errors) > 0 {
+		messages := make([]string, 0, len(result.Errors))
+		for _, msg := range result.Errors {
+			if msg.Location != nil {
+				messages = append(messages, fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column+1, msg.Text))
+			} else {
+				messages = append(messages, msg.Text)
+			}
+		}
+		return "", fmt.Errorf("error transpiling module: %s", strings.Join(messages, "; "))
+	}
+	return string(result.Code), nil
+}
+
+// isTypeScript reports whether the file at path is a TypeScript file
+func isTypeScript(path string) bool {
+	switch filepath.Ext(path) {
+	case ".ts", ".mts", ".cts":
+		return true
+	}
+	return false
+}
+
+// wrap wraps CommonJS code in a function without adding lines before it, keeping line numbers intact
+func wrap(code string) string {
+	return "(function (exports, require, module, __filename, __dirname, open) {" + code + "\n})"
+}
@@ -0,0 +1,42 @@
+package modules
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestTypeScriptErrorsReportOriginalPosition(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.ts": `interface User {
+  name: string;
+}
+
+type Handler = (user: User) => void;
+
+const greet: Handler = (user: User): void => {
+  const name: string = user.name;
+  throw new Error("failed for " + name);
+};
+
+greet({ name: "ada" });
+`,
+	})
+	path := filepath.Join(dir, "main.ts")
+
+	_, err := NewRegistry(goja.New(), NewLoader()).Require(path)
+	exception, ok := err.(*goja.Exception)
+	if !ok {
+		t.Fatalf("error = %v, want a JavaScript exception", err)
+	}
+	// Positions mapped through the source map carry its zero-based columns,
+	// so the new Error on line 9 is reported at column 8
+	stack := exception.String()
+	for _, want := range []string{"failed for ada", path + ":9:8", path + ":12:"} {
+		if !strings.Contains(stack, want) {
+			t.Errorf("stack does not contain %q:\n%s", want, stack)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dop251/goja"
+)
+
+// ReadFile returns the contents of the file at the absolute path, reading it from disk only once
+func (l *Loader) ReadFile(path string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if data, ok := l.files[path]; ok {
+		return data, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	l.files[path] = data
+	return data, nil
+}
+
+// EndInit marks the end of the init context, after which open() can no longer be called
+func (r *Registry) EndInit() {
+	r.initDone = true
+}
+
+// openFunc returns the open function for modules located in dir.
+// open(path) returns the file contents as a string, open(path, 'b') as an ArrayBuffer.
+func (r *Registry) openFunc(dir string) goja.Value {
+	return r.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		if r.initDone {
+			panic(r.vm.NewGoError(fmt.Errorf("open() can only be called in the init context")))
+		}
+
+		path := call.Argument(0).String()
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		data, err := r.loader.ReadFile(path)
+		if err != nil {
+			panic(r.vm.NewGoError(err))
+		}
+
+		if call.Argument(1).String() == "b" {
+			buffer := make([]byte, len(data))
+			copy(buffer, data)
+			return r.vm.ToValue(r.vm.NewArrayBuffer(buffer))
+		}
+		return r.vm.ToValue(string(data))
+	})
+}
@@ -0,0 +1,130 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Registry holds the module instances of a single runtime, so each module is evaluated once per runtime
+type Registry struct {
+	vm       *goja.Runtime
+	loader   *Loader
+	modules  map[string]*goja.Object
+	initDone bool
+}
+
+// NewRegistry creates a new Registry for the runtime
+func NewRegistry(
+	vm *goja.Runtime,
+	loader *Loader,
+) *Registry {
+	return &Registry{
+		vm:      vm,
+		loader:  loader,
+		modules: make(map[string]*goja.Object),
+	}
+}
+
+// Require evaluates the module at the path, unless it already was, and returns its exports
+func (r *Registry) Require(path string) (goja.Value, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving module path: %w", err)
+	}
+
+	if module, ok := r.modules[path]; ok {
+		return module.Get("exports"), nil
+	}
+
+	program, err := r.loader.Program(path)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper, err := r.vm.RunProgram(program)
+	if err != nil {
+		return nil, fmt.Errorf("error running module %s: %w", path, err)
+	}
+	fn, ok := goja.AssertFunction(wrapper)
+	if !ok {
+		return nil, fmt.Errorf("module %s did not compile to a function", path)
+	}
+
+	exports := r.vm.NewObject()
+	module := r.vm.NewObject()
+	if err := module.Set("exports", exports); err != nil {
+		return nil, fmt.Errorf("error setting module.exports: %w", err)
+	}
+	// Register the module before evaluating it so circular requires see the partial exports
+	r.modules[path] = module
+
+	dir := filepath.Dir(path)
+	args := []goja.Value{exports, r.requireFunc(dir), module, r.vm.ToValue(path), r.vm.ToValue(dir), r.openFunc(dir)}
+	if _, err := fn(goja.Undefined(), args...); err != nil {
+		delete(r.modules, path)
+		return nil, err
+	}
+
+	return module.Get("exports"), nil
+}
+
+// requireFunc returns the require function for modules located in dir.
+// Built-in modules are looked up by name, any other specifier is resolved as a local file.
+func (r *Registry) requireFunc(dir string) goja.Value {
+	return r.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		specifier := call.Argument(0).String()
+
+		if builtin, ok := r.loader.builtin(specifier); ok {
+			exports, err := builtin(r.vm, dir)
+			if err != nil {
+				panic(r.vm.NewGoError(err))
+			}
+			return exports
+		}
+
+		path, err := resolve(dir, specifier)
+		if err != nil {
+			panic(r.vm.NewGoError(err))
+		}
+
+		exports, err := r.Require(path)
+		if err != nil {
+			if exception, ok := err.(*goja.Exception); ok {
+				panic(exception)
+			}
+			panic(r.vm.NewGoError(err))
+		}
+		return exports
+	})
+}
+
+// resolvableExtensions are tried in order when a module specifier has no extension
+var resolvableExtensions = []string{".js", ".ts"}
+
+// resolve resolves a module specifier relative to dir, trying the resolvable extensions when the file does not exist
+func resolve(dir, specifier string) (string, error) {
+	if !strings.HasPrefix(specifier, "./") && !strings.HasPrefix(specifier, "../") && !filepath.IsAbs(specifier) {
+		return "", fmt.Errorf("unknown module %q, local modules must start with ./ or ../", specifier)
+	}
+
+	path := specifier
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, specifier)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if filepath.Ext(path) == "" {
+		for _, ext := range resolvableExtensions {
+			if _, err := os.Stat(path + ext); err == nil {
+				return path + ext, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cannot find module %q from %s", specifier, dir)
+}
@@ -0,0 +1,149 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// writeModules writes the files into a temporary directory and returns its path
+func writeModules(
+	t *testing.T,
+	files map[string]string,
+) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// requireExport requires the module in a new runtime and returns one of its exports
+func requireExport(
+	t *testing.T,
+	loader *Loader,
+	path string,
+	name string,
+) goja.Value {
+	t.Helper()
+	vm := goja.New()
+	exports, err := NewRegistry(vm, loader).Require(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exports.ToObject(vm).Get(name)
+}
+
+func TestRequireResolvesRelativeModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.js": `
+			import { add } from "./lib/math";
+			const helper = require("./lib/helper.ts");
+			export const result = add(1, 2) + helper.twice(3);
+		`,
+		"lib/math.js": `export function add(a, b) { return a + b; }`,
+		"lib/helper.ts": `
+			import { factor } from "../shared";
+			export function twice(n: number): number { return n * factor; }
+		`,
+		"shared.js": `module.exports = { factor: 2 };`,
+	})
+
+	if result := requireExport(t, NewLoader(), filepath.Join(dir, "main.js"), "result"); result.ToInteger() != 9 {
+		t.Errorf("result = %v, want 9", result)
+	}
+}
+
+func TestLoaderSharesProgramsAcrossRuntimes(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.js": `
+			const first = require("./lib");
+			const second = require("./lib.js");
+			exports.same = first === second;
+			exports.loads = globalThis.loads;
+		`,
+		"lib.js": `globalThis.loads = (globalThis.loads || 0) + 1;`,
+	})
+	main := filepath.Join(dir, "main.js")
+	loader := NewLoader()
+
+	// Each runtime evaluates a module once, however often it is required
+	for i := 0; i < 2; i++ {
+		if same := requireExport(t, loader, main, "same"); !same.ToBoolean() {
+			t.Error("requiring a module twice returned different exports")
+		}
+		if loads := requireExport(t, loader, main, "loads"); loads.ToInteger() != 1 {
+			t.Errorf("module evaluated %v times in one runtime", loads)
+		}
+	}
+
+	// Every runtime runs the same compiled program
+	program, err := loader.Program(filepath.Join(dir, "lib.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := loader.Program(filepath.Join(dir, "lib.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program != again {
+		t.Error("loader compiled the module again")
+	}
+	if len(loader.programs) != 2 {
+		t.Errorf("loader cached %d programs, want 2", len(loader.programs))
+	}
+}
+
+func TestRequireHandlesCircularModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.js": `
+			exports.name = "a";
+			const b = require("./b");
+			exports.seen = b.seen;
+		`,
+		"b.js": `
+			const a = require("./a");
+			exports.seen = a.name;
+		`,
+	})
+
+	if seen := requireExport(t, NewLoader(), filepath.Join(dir, "a.js"), "seen"); seen.String() != "a" {
+		t.Errorf("seen = %v, want the partial exports of a", seen)
+	}
+}
+
+func TestRequireReportsMissingModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"relative.js": `require("./missing");`,
+		"bare.js":     `require("lodash");`,
+	})
+	loader := NewLoader()
+
+	for _, tc := range []struct {
+		script string
+		err    string
+	}{
+		{"relative.js", `cannot find module "./missing"`},
+		{"bare.js", `unknown module "lodash"`},
+	} {
+		vm := goja.New()
+		_, err := NewRegistry(vm, loader).Require(filepath.Join(dir, tc.script))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: error = %v, want %q", tc.script, err, tc.err)
+		}
+	}
+
+	if _, err := NewRegistry(goja.New(), loader).Require(filepath.Join(dir, "absent.js")); err == nil || !strings.Contains(err.Error(), "error reading module") {
+		t.Errorf("error = %v, want a read error", err)
+	}
+}
@@ -0,0 +1,131 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// sharedData holds the elements of a SharedArray as JSON, so every runtime can decode them on access
+type sharedData struct {
+	once     sync.Once
+	elements []string
+	err      error
+}
+
+// sharedArray exposes sharedData to a single runtime as a lazy, read-only array
+type sharedArray struct {
+	data  *sharedData
+	parse goja.Callable
+	vm    *goja.Runtime
+}
+
+// RegisterSharedArray registers the SharedArray constructor, whose factory runs once per name across all runtimes.
+// new SharedArray(name, () => data) returns a read-only array whose elements are decoded when accessed.
+func RegisterSharedArray(
+	vm *goja.Runtime,
+	loader *Loader,
+) error {
+	parse, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	if !ok {
+		return fmt.Errorf("JSON.parse is not a function")
+	}
+
+	constructor := func(call goja.ConstructorCall) *goja.Object {
+		if goja.IsUndefined(call.Argument(0)) || call.Argument(0).String() == "" {
+			panic(vm.NewTypeError("SharedArray requires a name"))
+		}
+		name := call.Argument(0).String()
+		factory, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			panic(vm.NewTypeError("SharedArray requires a factory function"))
+		}
+
+		data := loader.sharedData(name)
+		data.once.Do(func() {
+			data.elements, data.err = buildElements(vm, factory)
+		})
+		if data.err != nil {
+			panic(vm.NewGoError(fmt.Errorf("error creating shared array %q: %w", name, data.err)))
+		}
+
+		return vm.NewDynamicArray(&sharedArray{data: data, parse: parse, vm: vm})
+	}
+
+	if err := vm.Set("SharedArray", constructor); err != nil {
+		return fmt.Errorf("failed to set SharedArray: %w", err)
+	}
+	return nil
+}
+
+// sharedData returns the shared data registered under name, creating an empty entry if there is none
+func (l *Loader) sharedData(name string) *sharedData {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, ok := l.sharedArrays[name]
+	if !ok {
+		data = &sharedData{}
+		l.sharedArrays[name] = data
+	}
+	return data
+}
+
+// buildElements runs the factory and encodes every element of the array it returns
+func buildElements(
+	vm *goja.Runtime,
+	factory goja.Callable,
+) ([]string, error) {
+	result, err := factory(goja.Undefined())
+	if err != nil {
+		return nil, err
+	}
+	if result == nil || goja.IsUndefined(result) || goja.IsNull(result) {
+		return nil, fmt.Errorf("factory must return an array")
+	}
+
+	array := result.ToObject(vm)
+	if array.ClassName() != "Array" {
+		return nil, fmt.Errorf("factory must return an array")
+	}
+
+	length := int(array.Get("length").ToInteger())
+	elements := make([]string, length)
+	for i := 0; i < length; i++ {
+		encoded, err := json.Marshal(array.Get(fmt.Sprint(i)))
+		if err != nil {
+			return nil, fmt.Errorf("error encoding element %d: %w", i, err)
+		}
+		elements[i] = string(encoded)
+	}
+	return elements, nil
+}
+
+// Len returns the number of elements
+func (a *sharedArray) Len() int {
+	return len(a.data.elements)
+}
+
+// Get decodes the element at idx into a new value owned by the runtime
+func (a *sharedArray) Get(idx int) goja.Value {
+	if idx < 0 || idx >= len(a.data.elements) {
+		return goja.Undefined()
+	}
+	value, err := a.parse(goja.Undefined(), a.vm.ToValue(a.data.elements[idx]))
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Set rejects writes, the array is read-only
+func (a *sharedArray) Set(int, goja.Value) bool {
+	return false
+}
+
+// SetLen rejects resizing, the array is read-only
+func (a *sharedArray) SetLen(int) bool {
+	return false
+}
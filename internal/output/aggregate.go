@@ -0,0 +1,122 @@
+package output
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+)
+
+// durationBounds are the upper bounds of the request duration histogram buckets
+var durationBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// seriesKey identifies a group of requests sharing the same labels
+type seriesKey struct {
+	method string
+	status string
+	url    string
+}
+
+// series holds the cumulative values of a group of requests
+type series struct {
+	requests     int64
+	failed       int64
+	durationSum  time.Duration
+	blockedSum   time.Duration
+	dataSent     int64
+	dataReceived int64
+	minDuration  time.Duration
+	maxDuration  time.Duration
+	// buckets holds the request count per duration bucket, with a final overflow bucket
+	buckets []int64
+	// durations holds the request durations recorded since the last flush
+	durations []time.Duration
+}
+
+// aggregator turns the raw request metrics into cumulative per-series values
+type aggregator struct {
+	metrics *metrics.Metrics
+	offset  int
+	series  map[seriesKey]*series
+}
+
+// newAggregator creates a new aggregator reading from the given metrics
+func newAggregator(m *metrics.Metrics) *aggregator {
+	return &aggregator{
+		metrics: m,
+		series:  make(map[seriesKey]*series),
+	}
+}
+
+// collect reads the requests recorded since the last call and adds them to the series
+func (a *aggregator) collect() []metrics.RequestMetrics {
+	for _, s := range a.series {
+		s.durations = s.durations[:0]
+	}
+
+	requests := a.metrics.RequestsSince(a.offset)
+	a.offset += len(requests)
+
+	for _, req := range requests {
+		key := keyFor(req)
+		s, ok := a.series[key]
+		if !ok {
+			s = &series{buckets: make([]int64, len(durationBounds)+1)}
+			a.series[key] = s
+		}
+		duration := req.Duration()
+		if s.requests == 0 || duration < s.minDuration {
+			s.minDuration = duration
+		}
+		if duration > s.maxDuration {
+			s.maxDuration = duration
+		}
+		s.buckets[bucketIndex(duration)]++
+		s.requests++
+		if req.Failed() {
+			s.failed++
+		}
+		s.durationSum += duration
+		s.blockedSum += req.Timings().Blocked
+		s.durations = append(s.durations, duration)
+		s.dataSent += req.DataSent
+		s.dataReceived += req.DataReceived
+	}
+
+	return requests
+}
+
+// bucketIndex returns the histogram bucket a duration falls into
+func bucketIndex(duration time.Duration) int {
+	for i, bound := range durationBounds {
+		if duration <= bound {
+			return i
+		}
+	}
+	return len(durationBounds)
+}
+
+// keyFor returns the series key of a request
+func keyFor(req metrics.RequestMetrics) seriesKey {
+	key := seriesKey{status: "0"}
+	if req.Request != nil {
+		key.method = req.Request.Method
+		key.url = metrics.EndpointURL(req.Request.URL)
+	}
+	if req.Response != nil {
+		key.status = strconv.Itoa(req.Response.StatusCode)
+	}
+	return key
+}
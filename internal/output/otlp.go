@@ -0,0 +1,284 @@
+package output
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	yalthttp "github.com/joakimcarlsson/yalt/internal/http"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+const defaultServiceName = "yalt"
+
+// OTLP exports metrics and request spans to an OpenTelemetry collector over OTLP/HTTP protobuf
+type OTLP struct {
+	endpoint      string
+	serviceName   string
+	headers       map[string]string
+	traces        bool
+	flushInterval time.Duration
+	client        *http.Client
+	aggregator    *aggregator
+	startTime     time.Time
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// NewOTLP creates a new OTLP output sending to the collector at the given base URL
+func NewOTLP(
+	endpoint string,
+	options models.OTLPOptions,
+	m *metrics.Metrics,
+) (*OTLP, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("otlp output requires a URL")
+	}
+
+	flushInterval, err := parseFlushInterval(options.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := options.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	return &OTLP{
+		endpoint:      strings.TrimSuffix(endpoint, "/"),
+		serviceName:   serviceName,
+		headers:       options.Headers,
+		traces:        options.Traces,
+		flushInterval: flushInterval,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		aggregator:    newAggregator(m),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// PropagatesTraces reports whether requests should carry a traceparent header
+func (o *OTLP) PropagatesTraces() bool {
+	return o.traces
+}
+
+// Start begins exporting every flush interval
+func (o *OTLP) Start() error {
+	o.startTime = time.Now()
+	go o.run()
+	return nil
+}
+
+// Stop exports the remaining metrics and spans and stops the output
+func (o *OTLP) Stop() error {
+	close(o.stop)
+	<-o.done
+	return o.flush()
+}
+
+// run flushes periodically until the output is stopped
+func (o *OTLP) run() {
+	defer close(o.done)
+
+	ticker := time.NewTicker(o.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+			if err := o.flush(); err != nil {
+				log.Printf("Error exporting to otlp: %v", err)
+			}
+		}
+	}
+}
+
+// flush exports the latest metrics and, when enabled, the spans of new requests
+func (o *OTLP) flush() error {
+	requests := o.aggregator.collect()
+	if len(o.aggregator.series) == 0 {
+		return nil
+	}
+
+	if err := o.send("/v1/metrics", o.encodeMetrics(time.Now())); err != nil {
+		return fmt.Errorf("error exporting metrics: %w", err)
+	}
+
+	if !o.traces || len(requests) == 0 {
+		return nil
+	}
+	if payload := o.encodeTraces(requests); payload != nil {
+		if err := o.send("/v1/traces", payload); err != nil {
+			return fmt.Errorf("error exporting traces: %w", err)
+		}
+	}
+	return nil
+}
+
+// send posts a protobuf payload to the given collector path
+func (o *OTLP) send(path string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, o.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range o.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// encodeMetrics encodes the aggregated series as an ExportMetricsServiceRequest
+func (o *OTLP) encodeMetrics(now time.Time) []byte {
+	start := uint64(o.startTime.UnixNano())
+	timestamp := uint64(now.UnixNano())
+
+	bounds := make([]float64, len(durationBounds))
+	for i, bound := range durationBounds {
+		bounds[i] = metrics.Milliseconds(bound)
+	}
+
+	var reqs, failed, blocked, sent, received, durations [][]byte
+	for key, s := range o.aggregator.series {
+		attributes := []otlpAttribute{
+			{key: "http.method", value: key.method},
+			{key: "http.status_code", value: key.status},
+			{key: "http.url", value: key.url},
+		}
+		reqs = append(reqs, encodeNumberDataPoint(attributes, start, timestamp, float64(s.requests)))
+		failed = append(failed, encodeNumberDataPoint(attributes, start, timestamp, float64(s.failed)))
+		blocked = append(blocked, encodeNumberDataPoint(attributes, start, timestamp, metrics.Milliseconds(s.blockedSum)))
+		sent = append(sent, encodeNumberDataPoint(attributes, start, timestamp, float64(s.dataSent)))
+		received = append(received, encodeNumberDataPoint(attributes, start, timestamp, float64(s.dataReceived)))
+		durations = append(durations, encodeHistogramDataPoint(
+			attributes,
+			start, timestamp,
+			uint64(s.requests),
+			metrics.Milliseconds(s.durationSum), metrics.Milliseconds(s.minDuration), metrics.Milliseconds(s.maxDuration),
+			s.buckets,
+			bounds,
+		))
+	}
+
+	var scopeMetrics []byte
+	scopeMetrics = appendMessage(scopeMetrics, 1, encodeScope())
+	scopeMetrics = appendMessage(scopeMetrics, 2, encodeSum("yalt.http_reqs", "{request}", reqs))
+	scopeMetrics = appendMessage(scopeMetrics, 2, encodeSum("yalt.http_req_failed", "{request}", failed))
+	scopeMetrics = appendMessage(scopeMetrics, 2, encodeSum("yalt.http_req_blocked", "ms", blocked))
+	scopeMetrics = appendMessage(scopeMetrics, 2, encodeSum("yalt.data_sent", "By", sent))
+	scopeMetrics = appendMessage(scopeMetrics, 2, encodeSum("yalt.data_received", "By", received))
+	scopeMetrics = appendMessage(scopeMetrics, 2, encodeHistogram("yalt.http_req_duration", "ms", durations))
+
+	var resourceMetrics []byte
+	resourceMetrics = appendMessage(resourceMetrics, 1, encodeResource(o.serviceName))
+	resourceMetrics = appendMessage(resourceMetrics, 2, scopeMetrics)
+
+	return appendMessage(nil, 1, resourceMetrics)
+}
+
+// encodeTraces encodes a client span for every traced request as an ExportTraceServiceRequest
+func (o *OTLP) encodeTraces(requests []metrics.RequestMetrics) []byte {
+	var scopeSpans []byte
+	scopeSpans = appendMessage(scopeSpans, 1, encodeScope())
+
+	spans := 0
+	for _, req := range requests {
+		span, ok := spanFor(req)
+		if !ok {
+			continue
+		}
+		scopeSpans = appendMessage(scopeSpans, 2, encodeSpan(span))
+		spans++
+	}
+	if spans == 0 {
+		return nil
+	}
+
+	var resourceSpans []byte
+	resourceSpans = appendMessage(resourceSpans, 1, encodeResource(o.serviceName))
+	resourceSpans = appendMessage(resourceSpans, 2, scopeSpans)
+
+	return appendMessage(nil, 1, resourceSpans)
+}
+
+// spanFor builds a client span from a request carrying a traceparent header
+func spanFor(req metrics.RequestMetrics) (otlpSpan, bool) {
+	if req.Request == nil {
+		return otlpSpan{}, false
+	}
+	parts := strings.Split(req.Request.Header.Get(yalthttp.TraceparentHeader), "-")
+	if len(parts) != 4 {
+		return otlpSpan{}, false
+	}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return otlpSpan{}, false
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return otlpSpan{}, false
+	}
+
+	span := otlpSpan{
+		traceID: traceID,
+		spanID:  spanID,
+		name:    "HTTP " + req.Request.Method,
+		start:   uint64(req.StartTime.UnixNano()),
+		end:     uint64(req.EndTime.UnixNano()),
+		attributes: []otlpAttribute{
+			{key: "http.method", value: req.Request.Method},
+			{key: "http.url", value: metrics.EndpointURL(req.Request.URL)},
+		},
+		failed: req.Failed(),
+	}
+	if req.Response != nil {
+		span.attributes = append(span.attributes, otlpAttribute{key: "http.status_code", value: req.Response.StatusCode})
+		span.statusMessage = req.Response.Status
+	}
+	if req.Error != nil {
+		span.statusMessage = req.Error.Error()
+	}
+
+	phases := []struct {
+		name string
+		time time.Time
+	}{
+		{"dns.start", req.DNSStart},
+		{"dns.done", req.DNSDone},
+		{"connect.start", req.ConnectStart},
+		{"connect.done", req.ConnectDone},
+		{"tls.start", req.TLSHandshakeStart},
+		{"tls.done", req.TLSHandshakeDone},
+		{"got_conn", req.GotConn},
+		{"wrote_headers", req.WroteHeaders},
+		{"wrote_request", req.WroteRequest},
+		{"first_byte", req.GotFirstResponseByte},
+	}
+	for _, phase := range phases {
+		if !phase.time.IsZero() {
+			span.events = append(span.events, otlpSpanEvent{name: phase.name, time: uint64(phase.time.UnixNano())})
+		}
+	}
+
+	return span, true
+}
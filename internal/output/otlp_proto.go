@@ -0,0 +1,205 @@
+package output
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// OTLP protobuf field numbers, see https://github.com/open-telemetry/opentelemetry-proto
+const (
+	aggregationTemporalityCumulative = 2
+	spanKindClient                   = 3
+	statusCodeOK                     = 1
+	statusCodeError                  = 2
+)
+
+// otlpAttribute is a string, int or double attribute of a resource, data point, span or event
+type otlpAttribute struct {
+	key   string
+	value interface{}
+}
+
+// appendMessage appends an embedded message field
+func appendMessage(buf []byte, num protowire.Number, msg []byte) []byte {
+	buf = protowire.AppendTag(buf, num, protowire.BytesType)
+	return protowire.AppendBytes(buf, msg)
+}
+
+// appendString appends a string field, skipping empty strings
+func appendString(buf []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, num, protowire.BytesType)
+	return protowire.AppendString(buf, value)
+}
+
+// appendFixed64 appends a fixed64 field
+func appendFixed64(buf []byte, num protowire.Number, value uint64) []byte {
+	buf = protowire.AppendTag(buf, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(buf, value)
+}
+
+// appendDouble appends a double field
+func appendDouble(buf []byte, num protowire.Number, value float64) []byte {
+	return appendFixed64(buf, num, math.Float64bits(value))
+}
+
+// appendVarint appends a varint field
+func appendVarint(buf []byte, num protowire.Number, value uint64) []byte {
+	buf = protowire.AppendTag(buf, num, protowire.VarintType)
+	return protowire.AppendVarint(buf, value)
+}
+
+// appendAttributes appends a repeated KeyValue field
+func appendAttributes(buf []byte, num protowire.Number, attributes []otlpAttribute) []byte {
+	for _, attr := range attributes {
+		var value []byte
+		switch v := attr.value.(type) {
+		case string:
+			value = appendString(value, 1, v)
+		case bool:
+			value = protowire.AppendTag(value, 2, protowire.VarintType)
+			value = protowire.AppendVarint(value, protowire.EncodeBool(v))
+		case int:
+			value = appendVarint(value, 3, uint64(v))
+		case int64:
+			value = appendVarint(value, 3, uint64(v))
+		case float64:
+			value = appendDouble(value, 4, v)
+		}
+
+		var kv []byte
+		kv = appendString(kv, 1, attr.key)
+		kv = appendMessage(kv, 2, value)
+		buf = appendMessage(buf, num, kv)
+	}
+	return buf
+}
+
+// encodeResource encodes an opentelemetry.proto.resource.v1.Resource
+func encodeResource(serviceName string) []byte {
+	return appendAttributes(nil, 1, []otlpAttribute{
+		{key: "service.name", value: serviceName},
+	})
+}
+
+// encodeScope encodes an opentelemetry.proto.common.v1.InstrumentationScope
+func encodeScope() []byte {
+	return appendString(nil, 1, "yalt")
+}
+
+// encodeSum encodes a cumulative monotonic opentelemetry.proto.metrics.v1.Metric with a Sum
+func encodeSum(name, unit string, points [][]byte) []byte {
+	var sum []byte
+	for _, point := range points {
+		sum = appendMessage(sum, 1, point)
+	}
+	sum = appendVarint(sum, 2, aggregationTemporalityCumulative)
+	sum = appendVarint(sum, 3, protowire.EncodeBool(true))
+
+	var metric []byte
+	metric = appendString(metric, 1, name)
+	metric = appendString(metric, 3, unit)
+	return appendMessage(metric, 7, sum)
+}
+
+// encodeNumberDataPoint encodes an opentelemetry.proto.metrics.v1.NumberDataPoint
+func encodeNumberDataPoint(attributes []otlpAttribute, start, now uint64, value float64) []byte {
+	var point []byte
+	point = appendFixed64(point, 2, start)
+	point = appendFixed64(point, 3, now)
+	point = appendDouble(point, 4, value)
+	return appendAttributes(point, 7, attributes)
+}
+
+// encodeHistogram encodes a cumulative opentelemetry.proto.metrics.v1.Metric with a Histogram
+func encodeHistogram(name, unit string, points [][]byte) []byte {
+	var histogram []byte
+	for _, point := range points {
+		histogram = appendMessage(histogram, 1, point)
+	}
+	histogram = appendVarint(histogram, 2, aggregationTemporalityCumulative)
+
+	var metric []byte
+	metric = appendString(metric, 1, name)
+	metric = appendString(metric, 3, unit)
+	return appendMessage(metric, 9, histogram)
+}
+
+// encodeHistogramDataPoint encodes an opentelemetry.proto.metrics.v1.HistogramDataPoint
+func encodeHistogramDataPoint(
+	attributes []otlpAttribute,
+	start, now uint64,
+	count uint64,
+	sum, min, max float64,
+	buckets []int64,
+	bounds []float64,
+) []byte {
+	var point []byte
+	point = appendFixed64(point, 2, start)
+	point = appendFixed64(point, 3, now)
+	point = appendFixed64(point, 4, count)
+	point = appendDouble(point, 5, sum)
+
+	var packedBuckets []byte
+	for _, bucket := range buckets {
+		packedBuckets = protowire.AppendFixed64(packedBuckets, uint64(bucket))
+	}
+	point = appendMessage(point, 6, packedBuckets)
+
+	var packedBounds []byte
+	for _, bound := range bounds {
+		packedBounds = protowire.AppendFixed64(packedBounds, math.Float64bits(bound))
+	}
+	point = appendMessage(point, 7, packedBounds)
+
+	point = appendAttributes(point, 9, attributes)
+	point = appendDouble(point, 11, min)
+	return appendDouble(point, 12, max)
+}
+
+// otlpSpanEvent is a timestamped event attached to a span
+type otlpSpanEvent struct {
+	name string
+	time uint64
+}
+
+// otlpSpan holds the fields of an opentelemetry.proto.trace.v1.Span
+type otlpSpan struct {
+	traceID, spanID []byte
+	name            string
+	start, end      uint64
+	attributes      []otlpAttribute
+	events          []otlpSpanEvent
+	failed          bool
+	statusMessage   string
+}
+
+// encodeSpan encodes an opentelemetry.proto.trace.v1.Span
+func encodeSpan(span otlpSpan) []byte {
+	var buf []byte
+	buf = appendMessage(buf, 1, span.traceID)
+	buf = appendMessage(buf, 2, span.spanID)
+	buf = appendString(buf, 5, span.name)
+	buf = appendVarint(buf, 6, spanKindClient)
+	buf = appendFixed64(buf, 7, span.start)
+	buf = appendFixed64(buf, 8, span.end)
+	buf = appendAttributes(buf, 9, span.attributes)
+	for _, event := range span.events {
+		var e []byte
+		e = appendFixed64(e, 1, event.time)
+		e = appendString(e, 2, event.name)
+		buf = appendMessage(buf, 11, e)
+	}
+
+	var status []byte
+	if span.failed {
+		status = appendString(status, 2, span.statusMessage)
+		status = appendVarint(status, 3, statusCodeError)
+	} else {
+		status = appendVarint(status, 3, statusCodeOK)
+	}
+	return appendMessage(buf, 15, status)
+}
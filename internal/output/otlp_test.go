@@ -0,0 +1,186 @@
+package output
+
+import (
+	"encoding/hex"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoField is a decoded protobuf field, holding either bytes or a scalar
+type protoField struct {
+	num    protowire.Number
+	bytes  []byte
+	scalar uint64
+}
+
+// parseFields decodes the top-level fields of a protobuf message
+func parseFields(
+	t *testing.T,
+	data []byte,
+) []protoField {
+	t.Helper()
+	var fields []protoField
+	forEachField(t, data, func(num protowire.Number, value []byte, scalar uint64) {
+		fields = append(fields, protoField{num: num, bytes: value, scalar: scalar})
+	})
+	return fields
+}
+
+// fieldsOf returns the fields with the given number
+func fieldsOf(
+	fields []protoField,
+	num protowire.Number,
+) []protoField {
+	var matching []protoField
+	for _, field := range fields {
+		if field.num == num {
+			matching = append(matching, field)
+		}
+	}
+	return matching
+}
+
+// path descends through nested messages, taking the first field with each number
+func path(
+	t *testing.T,
+	data []byte,
+	nums ...protowire.Number,
+) []protoField {
+	t.Helper()
+	fields := parseFields(t, data)
+	for _, num := range nums {
+		matching := fieldsOf(fields, num)
+		if len(matching) == 0 {
+			t.Fatalf("field %d not found", num)
+		}
+		fields = parseFields(t, matching[0].bytes)
+	}
+	return fields
+}
+
+func TestOTLPExportsMetricsAndSpans(t *testing.T) {
+	var mu sync.Mutex
+	payloads := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		payloads[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	spanID := "00f067aa0ba902b7"
+	u, _ := url.Parse("https://example.com/items?page=1")
+	req := &http.Request{Method: "GET", URL: u, Header: http.Header{}}
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	start := time.Now()
+	m := metrics.NewMetrics(nil)
+	m.AddRequestMetrics(metrics.RequestMetrics{
+		StartTime:            start,
+		GotConn:              start.Add(time.Millisecond),
+		GotFirstResponseByte: start.Add(20 * time.Millisecond),
+		EndTime:              start.Add(25 * time.Millisecond),
+		Request:              req,
+		Response:             &http.Response{StatusCode: 503, Status: "503 Service Unavailable"},
+	})
+
+	out, err := NewOTLP(server.URL+"/", models.OTLPOptions{
+		ServiceName: "checkout",
+		Headers:     map[string]string{"X-Api-Key": "secret"},
+		Traces:      true,
+	}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// ExportMetricsServiceRequest.resource_metrics.scope_metrics.metrics
+	resourceMetrics := path(t, payloads["/v1/metrics"], 1)
+	serviceName := path(t, fieldsOf(resourceMetrics, 1)[0].bytes, 1, 2)
+	if got := string(fieldsOf(serviceName, 1)[0].bytes); got != "checkout" {
+		t.Errorf("service.name = %q, want checkout", got)
+	}
+	scopeMetrics := parseFields(t, fieldsOf(resourceMetrics, 2)[0].bytes)
+	sums := make(map[string]float64)
+	histograms := make(map[string]protoField)
+	for _, metric := range fieldsOf(scopeMetrics, 2) {
+		fields := parseFields(t, metric.bytes)
+		name := string(fieldsOf(fields, 1)[0].bytes)
+		if sum := fieldsOf(fields, 7); len(sum) > 0 {
+			point := path(t, sum[0].bytes, 1)
+			sums[name] = math.Float64frombits(fieldsOf(point, 4)[0].scalar)
+		}
+		if histogram := fieldsOf(fields, 9); len(histogram) > 0 {
+			histograms[name] = histogram[0]
+		}
+	}
+	for name, want := range map[string]float64{
+		"yalt.http_reqs":       1,
+		"yalt.http_req_failed": 1,
+	} {
+		if got, ok := sums[name]; !ok || got != want {
+			t.Errorf("%s = %v (found %v), want %v", name, got, ok, want)
+		}
+	}
+	histogram, ok := histograms["yalt.http_req_duration"]
+	if !ok {
+		t.Fatal("yalt.http_req_duration histogram not exported")
+	}
+	point := path(t, histogram.bytes, 1)
+	if count := fieldsOf(point, 4)[0].scalar; count != 1 {
+		t.Errorf("histogram count = %d, want 1", count)
+	}
+	if sum := math.Float64frombits(fieldsOf(point, 5)[0].scalar); math.Abs(sum-25) > 1e-9 {
+		t.Errorf("histogram sum = %v, want 25", sum)
+	}
+
+	// ExportTraceServiceRequest.resource_spans.scope_spans.spans
+	scopeSpans := path(t, payloads["/v1/traces"], 1, 2)
+	spans := fieldsOf(scopeSpans, 2)
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := parseFields(t, spans[0].bytes)
+	if got := hex.EncodeToString(fieldsOf(span, 1)[0].bytes); got != traceID {
+		t.Errorf("trace id = %s, want %s", got, traceID)
+	}
+	if got := hex.EncodeToString(fieldsOf(span, 2)[0].bytes); got != spanID {
+		t.Errorf("span id = %s, want %s", got, spanID)
+	}
+	if got := string(fieldsOf(span, 5)[0].bytes); got != "HTTP GET" {
+		t.Errorf("span name = %q", got)
+	}
+	var events []string
+	for _, event := range fieldsOf(span, 11) {
+		events = append(events, string(fieldsOf(parseFields(t, event.bytes), 2)[0].bytes))
+	}
+	if len(events) != 2 || events[0] != "got_conn" || events[1] != "first_byte" {
+		t.Errorf("span events = %v, want [got_conn first_byte]", events)
+	}
+	status := parseFields(t, fieldsOf(span, 15)[0].bytes)
+	if code := fieldsOf(status, 3)[0].scalar; code != statusCodeError {
+		t.Errorf("status code = %d, want error", code)
+	}
+}
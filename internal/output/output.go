@@ -0,0 +1,81 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// Output exports metrics collected during a test run to an external system
+type Output interface {
+	// Start begins exporting metrics in the background
+	Start() error
+	// Stop flushes any remaining metrics and stops exporting
+	Stop() error
+}
+
+// New creates an Output from a --out specification in the form name=argument
+func New(
+	spec string,
+	options *models.Options,
+	m *metrics.Metrics,
+) (Output, error) {
+	name, arg, _ := strings.Cut(spec, "=")
+	switch name {
+	case "prometheus-rw":
+		return NewPrometheusRW(arg, options.Outputs.PrometheusRW, m)
+	case "otlp":
+		return NewOTLP(arg, options.Outputs.OTLP, m)
+	case "statsd":
+		return NewStatsD(arg, options.Outputs.StatsD, m)
+	default:
+		return nil, fmt.Errorf("unknown output %q", name)
+	}
+}
+
+// ValidateOptions checks the options of every output with the parsers their constructors use,
+// so invalid options fail before the test starts even when the output is not enabled
+func ValidateOptions(outputs *models.OutputOptions) error {
+	for _, output := range []struct {
+		name     string
+		interval string
+	}{
+		{"prometheusRW", outputs.PrometheusRW.FlushInterval},
+		{"otlp", outputs.OTLP.FlushInterval},
+		{"statsd", outputs.StatsD.FlushInterval},
+	} {
+		if _, err := parseFlushInterval(output.interval); err != nil {
+			return fmt.Errorf("invalid %s options: %w", output.name, err)
+		}
+	}
+	if _, err := parseRetries(outputs.PrometheusRW.Retries); err != nil {
+		return fmt.Errorf("invalid prometheusRW options: %w", err)
+	}
+	if _, err := parseBufferSize(outputs.StatsD.BufferSize); err != nil {
+		return fmt.Errorf("invalid statsd options: %w", err)
+	}
+	return nil
+}
+
+// TracePropagator is implemented by outputs that need trace context injected into outgoing requests
+type TracePropagator interface {
+	PropagatesTraces() bool
+}
+
+// parseFlushInterval parses a flush interval, falling back to the default when empty
+func parseFlushInterval(interval string) (time.Duration, error) {
+	if interval == "" {
+		return defaultFlushInterval, nil
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid flush interval: %w", err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid flush interval %q: must be greater than 0", interval)
+	}
+	return d, nil
+}
@@ -0,0 +1,44 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+func TestValidateOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		set   func(*models.OutputOptions)
+		valid bool
+	}{
+		{"defaults", func(*models.OutputOptions) {}, true},
+		{"positive flush interval", func(o *models.OutputOptions) { o.OTLP.FlushInterval = "2s" }, true},
+		{"prometheusRW zero flush interval", func(o *models.OutputOptions) { o.PrometheusRW.FlushInterval = "0s" }, false},
+		{"prometheusRW unparseable flush interval", func(o *models.OutputOptions) { o.PrometheusRW.FlushInterval = "soon" }, false},
+		{"otlp negative flush interval", func(o *models.OutputOptions) { o.OTLP.FlushInterval = "-1m" }, false},
+		{"statsd zero flush interval", func(o *models.OutputOptions) { o.StatsD.FlushInterval = "0" }, false},
+		{"retries disabled", func(o *models.OutputOptions) { o.PrometheusRW.Retries = intPtr(0) }, true},
+		{"retries", func(o *models.OutputOptions) { o.PrometheusRW.Retries = intPtr(5) }, true},
+		{"negative retries", func(o *models.OutputOptions) { o.PrometheusRW.Retries = intPtr(-1) }, false},
+		{"negative buffer size", func(o *models.OutputOptions) { o.StatsD.BufferSize = -1 }, false},
+	} {
+		var outputs models.OutputOptions
+		tc.set(&outputs)
+		err := ValidateOptions(&outputs)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: got error %v, want valid %v", tc.name, err, tc.valid)
+			continue
+		}
+
+		// The constructors reject the same options
+		m := metrics.NewMetrics(nil)
+		_, promErr := NewPrometheusRW("http://localhost:9090", outputs.PrometheusRW, m)
+		_, otlpErr := NewOTLP("http://localhost:4318", outputs.OTLP, m)
+		_, statsdErr := NewStatsD("127.0.0.1:8125", outputs.StatsD, m)
+		if constructorsValid := promErr == nil && otlpErr == nil && statsdErr == nil; constructorsValid != tc.valid {
+			t.Errorf("%s: constructors returned %v, %v and %v, want valid %v", tc.name, promErr, otlpErr, statsdErr, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	defaultFlushInterval = 5 * time.Second
+	defaultRetries       = 3
+	retryBackoff         = 500 * time.Millisecond
+)
+
+var quantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// PrometheusRW pushes aggregated metrics to a Prometheus remote-write endpoint
+type PrometheusRW struct {
+	url           string
+	labels        map[string]string
+	retries       int
+	flushInterval time.Duration
+	client        *http.Client
+	aggregator    *aggregator
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// promSample is a single remote-write time series with one sample
+type promSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// NewPrometheusRW creates a new Prometheus remote-write output
+func NewPrometheusRW(
+	url string,
+	options models.PrometheusRWOptions,
+	m *metrics.Metrics,
+) (*PrometheusRW, error) {
+	if url == "" {
+		return nil, fmt.Errorf("prometheus-rw output requires a URL")
+	}
+
+	flushInterval, err := parseFlushInterval(options.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	retries, err := parseRetries(options.Retries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrometheusRW{
+		url:           url,
+		labels:        options.Labels,
+		retries:       retries,
+		flushInterval: flushInterval,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		aggregator:    newAggregator(m),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// Start begins pushing metrics every flush interval
+func (p *PrometheusRW) Start() error {
+	go p.run()
+	return nil
+}
+
+// Stop pushes the remaining metrics and stops the output
+func (p *PrometheusRW) Stop() error {
+	close(p.stop)
+	<-p.done
+	return p.flush()
+}
+
+// run flushes metrics periodically until the output is stopped
+func (p *PrometheusRW) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if err := p.flush(); err != nil {
+				log.Printf("Error pushing metrics to prometheus: %v", err)
+			}
+		}
+	}
+}
+
+// flush collects the latest metrics and sends them to the remote-write endpoint
+func (p *PrometheusRW) flush() error {
+	p.aggregator.collect()
+	samples := p.samples()
+	if len(samples) == 0 {
+		return nil
+	}
+
+	payload := snappy.Encode(nil, encodeWriteRequest(samples, time.Now()))
+
+	var err error
+	for attempt := 0; attempt <= p.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryBackoff * time.Duration(attempt))
+		}
+		var retry bool
+		if retry, err = p.send(payload); err == nil || !retry {
+			return err
+		}
+	}
+	return fmt.Errorf("giving up after %d retries: %w", p.retries, err)
+}
+
+// send posts a single remote-write payload and reports whether a failure is retryable
+func (p *PrometheusRW) send(payload []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+}
+
+// samples converts the aggregated series into remote-write samples
+func (p *PrometheusRW) samples() []promSample {
+	var samples []promSample
+	add := func(name string, key seriesKey, extra map[string]string, value float64) {
+		labels := map[string]string{
+			"__name__": name,
+			"method":   key.method,
+			"status":   key.status,
+			"url":      key.url,
+		}
+		for k, v := range p.labels {
+			labels[k] = v
+		}
+		for k, v := range extra {
+			labels[k] = v
+		}
+		samples = append(samples, promSample{labels: labels, value: value})
+	}
+
+	for key, s := range p.aggregator.series {
+		add("yalt_http_reqs_total", key, nil, float64(s.requests))
+		add("yalt_http_req_failed_total", key, nil, float64(s.failed))
+		add("yalt_http_req_duration_seconds_sum", key, nil, s.durationSum.Seconds())
+		add("yalt_http_req_duration_seconds_count", key, nil, float64(s.requests))
+		add("yalt_http_req_blocked_seconds_sum", key, nil, s.blockedSum.Seconds())
+		add("yalt_data_sent_bytes_total", key, nil, float64(s.dataSent))
+		add("yalt_data_received_bytes_total", key, nil, float64(s.dataReceived))
+
+		if len(s.durations) == 0 {
+			continue
+		}
+		sort.Slice(s.durations, func(i, j int) bool { return s.durations[i] < s.durations[j] })
+		for _, q := range quantiles {
+			value := s.durations[int(q*float64(len(s.durations)-1))]
+			quantile := map[string]string{"quantile": strconv.FormatFloat(q, 'f', -1, 64)}
+			add("yalt_http_req_duration_seconds", key, quantile, value.Seconds())
+		}
+	}
+	return samples
+}
+
+// encodeWriteRequest encodes samples as a prometheus.WriteRequest protobuf message
+func encodeWriteRequest(samples []promSample, timestamp time.Time) []byte {
+	var buf []byte
+	for _, sample := range samples {
+		var ts []byte
+
+		names := make([]string, 0, len(sample.labels))
+		for name, value := range sample.labels {
+			if value != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, sample.labels[name])
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+
+		var s []byte
+		s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(sample.value))
+		s = protowire.AppendTag(s, 2, protowire.VarintType)
+		s = protowire.AppendVarint(s, uint64(timestamp.UnixMilli()))
+
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, s)
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts)
+	}
+	return buf
+}
+
+// parseRetries returns how often a failed push is retried, falling back to the default when unset
+func parseRetries(retries *int) (int, error) {
+	if retries == nil {
+		return defaultRetries, nil
+	}
+	if *retries < 0 {
+		return 0, fmt.Errorf("retries cannot be negative")
+	}
+	return *retries, nil
+}
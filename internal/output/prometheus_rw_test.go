@@ -0,0 +1,242 @@
+package output
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodedSeries is a time series decoded from a remote-write request
+type decodedSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest decodes a prometheus.WriteRequest protobuf message
+func decodeWriteRequest(
+	t *testing.T,
+	data []byte,
+) []decodedSeries {
+	t.Helper()
+	var series []decodedSeries
+	forEachField(t, data, func(num protowire.Number, value []byte, _ uint64) {
+		if num != 1 {
+			return
+		}
+		s := decodedSeries{labels: make(map[string]string)}
+		forEachField(t, value, func(num protowire.Number, value []byte, _ uint64) {
+			switch num {
+			case 1:
+				var name, labelValue string
+				forEachField(t, value, func(num protowire.Number, value []byte, _ uint64) {
+					if num == 1 {
+						name = string(value)
+					} else if num == 2 {
+						labelValue = string(value)
+					}
+				})
+				s.labels[name] = labelValue
+			case 2:
+				forEachField(t, value, func(num protowire.Number, _ []byte, scalar uint64) {
+					if num == 1 {
+						s.value = math.Float64frombits(scalar)
+					} else if num == 2 {
+						s.timestamp = int64(scalar)
+					}
+				})
+			}
+		})
+		series = append(series, s)
+	})
+	return series
+}
+
+// forEachField calls fn for every field of a protobuf message, passing bytes fields as value and numbers as scalar
+func forEachField(
+	t *testing.T,
+	data []byte,
+	fn func(num protowire.Number, value []byte, scalar uint64),
+) {
+	t.Helper()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		data = data[n:]
+		switch typ {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				t.Fatalf("invalid bytes field: %v", protowire.ParseError(n))
+			}
+			fn(num, value, 0)
+			data = data[n:]
+		case protowire.Fixed64Type:
+			value, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				t.Fatalf("invalid fixed64 field: %v", protowire.ParseError(n))
+			}
+			fn(num, nil, value)
+			data = data[n:]
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				t.Fatalf("invalid varint field: %v", protowire.ParseError(n))
+			}
+			fn(num, nil, value)
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+}
+
+// recordRequest adds a completed request to the metrics
+func recordRequest(
+	m *metrics.Metrics,
+	method string,
+	rawURL string,
+	status int,
+	duration time.Duration,
+) {
+	u, _ := url.Parse(rawURL)
+	start := time.Now()
+	m.AddRequestMetrics(metrics.RequestMetrics{
+		StartTime: start,
+		EndTime:   start.Add(duration),
+		Request:   &http.Request{Method: method, URL: u},
+		Response:  &http.Response{StatusCode: status},
+		DataSent:  100,
+	})
+}
+
+func TestPrometheusRWPushesWriteRequest(t *testing.T) {
+	var mu sync.Mutex
+	var received []decodedSeries
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("error decoding snappy body: %v", err)
+			return
+		}
+		mu.Lock()
+		received = append(received, decodeWriteRequest(t, data)...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := metrics.NewMetrics(nil)
+	recordRequest(m, "GET", "https://example.com/items?page=1", 200, 40*time.Millisecond)
+	recordRequest(m, "GET", "https://example.com/items?page=2", 200, 60*time.Millisecond)
+
+	out, err := NewPrometheusRW(server.URL, models.PrometheusRWOptions{
+		Labels:        map[string]string{"testid": "ci-42"},
+		FlushInterval: "1h",
+	}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	values := make(map[string]float64)
+	for _, s := range received {
+		if s.labels["method"] != "GET" || s.labels["status"] != "200" || s.labels["url"] != "https://example.com/items" {
+			t.Errorf("unexpected series labels %v", s.labels)
+		}
+		if s.labels["testid"] != "ci-42" {
+			t.Errorf("missing custom label in %v", s.labels)
+		}
+		if s.timestamp == 0 {
+			t.Errorf("missing timestamp in %v", s.labels)
+		}
+		name := s.labels["__name__"]
+		if quantile := s.labels["quantile"]; quantile != "" {
+			name += "{quantile=" + quantile + "}"
+		}
+		values[name] = s.value
+	}
+
+	for name, want := range map[string]float64{
+		"yalt_http_reqs_total":                          2,
+		"yalt_http_req_failed_total":                    0,
+		"yalt_http_req_duration_seconds_sum":            0.1,
+		"yalt_http_req_duration_seconds_count":          2,
+		"yalt_data_sent_bytes_total":                    200,
+		"yalt_http_req_duration_seconds{quantile=0.5}":  0.04,
+		"yalt_http_req_duration_seconds{quantile=0.99}": 0.04,
+	} {
+		got, ok := values[name]
+		if !ok {
+			t.Errorf("series %s not received", name)
+		} else if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPrometheusRWRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		retries  *int
+		attempts int
+	}{
+		{"default", nil, defaultRetries + 1},
+		{"disabled", intPtr(0), 1},
+		{"one", intPtr(1), 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				attempts++
+				mu.Unlock()
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			m := metrics.NewMetrics(nil)
+			recordRequest(m, "GET", "https://example.com/", 200, time.Millisecond)
+			out, err := NewPrometheusRW(server.URL, models.PrometheusRWOptions{Retries: tc.retries}, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.client.Timeout = time.Second
+
+			if err := out.flush(); err == nil {
+				t.Fatal("expected flush to fail")
+			}
+			if attempts != tc.attempts {
+				t.Errorf("got %d attempts, want %d", attempts, tc.attempts)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
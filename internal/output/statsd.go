@@ -0,0 +1,208 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+const (
+	defaultStatsDNamespace = "yalt."
+	// defaultStatsDBufferSize keeps packets below the typical Ethernet MTU
+	defaultStatsDBufferSize = 1432
+)
+
+// tagValueReplacer replaces the characters that separate tags, values and lines in the DogStatsD format
+var tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "\n", "_", "\r", "_")
+
+// StatsD sends request counters and timings to a StatsD or DogStatsD agent over UDP
+type StatsD struct {
+	conn          net.Conn
+	namespace     string
+	enableTags    bool
+	blocklist     map[string]bool
+	bufferSize    int
+	flushInterval time.Duration
+	metrics       *metrics.Metrics
+	offset        int
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// NewStatsD creates a new StatsD output sending to the given host:port
+func NewStatsD(
+	addr string,
+	options models.StatsDOptions,
+	m *metrics.Metrics,
+) (*StatsD, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("statsd output requires an address")
+	}
+
+	flushInterval, err := parseFlushInterval(options.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	bufferSize, err := parseBufferSize(options.BufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to statsd: %w", err)
+	}
+
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = defaultStatsDNamespace
+	}
+
+	blocklist := make(map[string]bool, len(options.TagBlocklist))
+	for _, tag := range options.TagBlocklist {
+		blocklist[tag] = true
+	}
+
+	return &StatsD{
+		conn:          conn,
+		namespace:     namespace,
+		enableTags:    options.EnableTags,
+		blocklist:     blocklist,
+		bufferSize:    bufferSize,
+		flushInterval: flushInterval,
+		metrics:       m,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// Start begins sending metrics every flush interval
+func (s *StatsD) Start() error {
+	go s.run()
+	return nil
+}
+
+// Stop sends the remaining metrics and closes the connection
+func (s *StatsD) Stop() error {
+	close(s.stop)
+	<-s.done
+	err := s.flush()
+	if closeErr := s.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// run flushes periodically until the output is stopped
+func (s *StatsD) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.flush(); err != nil {
+				log.Printf("Error sending metrics to statsd: %v", err)
+			}
+		}
+	}
+}
+
+// flush sends the requests recorded since the last flush in batched packets
+func (s *StatsD) flush() error {
+	requests := s.metrics.RequestsSince(s.offset)
+	s.offset += len(requests)
+
+	var packet bytes.Buffer
+	send := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := s.conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+
+	for _, req := range requests {
+		for _, line := range s.lines(req) {
+			if packet.Len() > 0 && packet.Len()+1+len(line) > s.bufferSize {
+				if err := send(); err != nil {
+					return fmt.Errorf("error writing packet: %w", err)
+				}
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		}
+	}
+
+	if err := send(); err != nil {
+		return fmt.Errorf("error writing packet: %w", err)
+	}
+	return nil
+}
+
+// lines returns the StatsD lines describing a single request
+func (s *StatsD) lines(req metrics.RequestMetrics) []string {
+	tags := s.tags(req)
+	lines := []string{
+		s.namespace + "http_reqs:1|c" + tags,
+		s.namespace + "http_req_duration:" + strconv.FormatFloat(metrics.Milliseconds(req.Duration()), 'f', 3, 64) + "|ms" + tags,
+		s.namespace + "http_req_blocked:" + strconv.FormatFloat(metrics.Milliseconds(req.Timings().Blocked), 'f', 3, 64) + "|ms" + tags,
+		s.namespace + "data_sent:" + strconv.FormatInt(req.DataSent, 10) + "|c" + tags,
+		s.namespace + "data_received:" + strconv.FormatInt(req.DataReceived, 10) + "|c" + tags,
+	}
+	if req.Failed() {
+		lines = append(lines, s.namespace+"http_req_failed:1|c"+tags)
+	}
+	return lines
+}
+
+// tags returns the DogStatsD tag block for a request, or an empty string when tags are disabled
+func (s *StatsD) tags(req metrics.RequestMetrics) string {
+	if !s.enableTags {
+		return ""
+	}
+
+	key := keyFor(req)
+	candidates := []struct{ name, value string }{
+		{"method", key.method},
+		{"status", key.status},
+		{"url", key.url},
+	}
+
+	tags := make([]string, 0, len(candidates))
+	for _, tag := range candidates {
+		if s.blocklist[tag.name] || tag.value == "" {
+			continue
+		}
+		tags = append(tags, tag.name+":"+tagValueReplacer.Replace(tag.value))
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return "|#" + strings.Join(tags, ",")
+}
+
+// parseBufferSize returns the largest packet size, falling back to the default when unset
+func parseBufferSize(size int) (int, error) {
+	if size < 0 {
+		return 0, fmt.Errorf("buffer size cannot be negative")
+	}
+	if size == 0 {
+		return defaultStatsDBufferSize, nil
+	}
+	return size, nil
+}
@@ -0,0 +1,123 @@
+package output
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// listenUDP starts a UDP listener and returns it with its address
+func listenUDP(t *testing.T) (*net.UDPConn, string) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().String()
+}
+
+// readPackets reads UDP packets until none arrives for a short while
+func readPackets(
+	t *testing.T,
+	conn *net.UDPConn,
+) []string {
+	t.Helper()
+	var packets []string
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return packets
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func TestStatsDSendsBatchedLines(t *testing.T) {
+	conn, addr := listenUDP(t)
+
+	m := metrics.NewMetrics(nil)
+	recordRequest(m, "GET", "https://example.com/items", 200, 12*time.Millisecond)
+	recordRequest(m, "POST", "https://example.com/orders", 500, 30*time.Millisecond)
+
+	out, err := NewStatsD(addr, models.StatsDOptions{
+		Namespace:     "perf.",
+		EnableTags:    true,
+		TagBlocklist:  []string{"url"},
+		BufferSize:    200,
+		FlushInterval: "1h",
+	}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := readPackets(t, conn)
+	if len(packets) < 2 {
+		t.Fatalf("got %d packets, want the lines split over several", len(packets))
+	}
+	var lines []string
+	for _, packet := range packets {
+		if len(packet) > 200 {
+			t.Errorf("packet of %d bytes exceeds the buffer size", len(packet))
+		}
+		lines = append(lines, strings.Split(packet, "\n")...)
+	}
+
+	for _, want := range []string{
+		"perf.http_reqs:1|c|#method:GET,status:200",
+		"perf.http_req_duration:12.000|ms|#method:GET,status:200",
+		"perf.data_sent:100|c|#method:GET,status:200",
+		"perf.http_req_failed:1|c|#method:POST,status:500",
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("line %q not sent, got %v", want, lines)
+		}
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "url:") {
+			t.Errorf("blocklisted url tag sent: %q", line)
+		}
+	}
+}
+
+func TestStatsDSanitizesTagValues(t *testing.T) {
+	s := &StatsD{enableTags: true}
+	m := metrics.NewMetrics(nil)
+	recordRequest(m, "GET", "https://example.com/a,b?x=1", 200, time.Millisecond)
+	req := m.RequestsSince(0)[0]
+	// An opaque URL is written as is, so the separators reach the tag value unescaped
+	req.Request.URL = &url.URL{Scheme: "https", Opaque: "//example.com/a,b|c\nd"}
+
+	tags := s.tags(req)
+	if got, want := tags, "|#method:GET,status:200,url:https://example.com/a_b_c_d"; got != want {
+		t.Errorf("tags = %q, want %q", got, want)
+	}
+}
+
+func TestStatsDWithoutTags(t *testing.T) {
+	s := &StatsD{namespace: "yalt."}
+	m := metrics.NewMetrics(nil)
+	recordRequest(m, "GET", "https://example.com/", 200, time.Millisecond)
+	for _, line := range s.lines(m.RequestsSince(0)[0]) {
+		if strings.Contains(line, "|#") {
+			t.Errorf("tags sent while disabled: %q", line)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package report
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 200
+)
+
+//go:embed templates/report.html
+var templates embed.FS
+
+// HTML renders the summary as a single self-contained HTML file
+type HTML struct {
+	path string
+}
+
+// htmlReport is the data passed to the HTML template
+type htmlReport struct {
+	Summary     *metrics.Summary
+	GeneratedAt time.Time
+	Options     string
+	Stages      []htmlStage
+	Trends      []htmlTrend
+	Charts      []htmlChart
+}
+
+// htmlTrend is a named row of the request timings table
+type htmlTrend struct {
+	Name  string
+	Trend metrics.Trend
+}
+
+// htmlStage is a stage positioned on the stage timeline
+type htmlStage struct {
+	Number   int
+	Stage    models.Stage
+	Offset   float64
+	Width    float64
+	StartsAt time.Duration
+}
+
+// htmlChart is a line chart rendered as inline SVG
+type htmlChart struct {
+	Title  string
+	Unit   string
+	Max    float64
+	Width  int
+	Height int
+	Series []htmlSeries
+}
+
+// htmlSeries is a single line of a chart
+type htmlSeries struct {
+	Name   string
+	Color  string
+	Points string
+}
+
+// Write renders the report to the configured path
+func (h *HTML) Write(
+	summary *metrics.Summary,
+	options *models.Options,
+) error {
+	tmpl, err := template.New("report.html").Funcs(template.FuncMap{
+		"ms": func(d time.Duration) string {
+			return fmt.Sprintf("%.2f", metrics.Milliseconds(d))
+		},
+		"percent": func(v float64) string {
+			return fmt.Sprintf("%.2f%%", v*100)
+		},
+		"round": func(d time.Duration) time.Duration {
+			return d.Round(time.Second)
+		},
+	}).ParseFS(templates, "templates/report.html")
+	if err != nil {
+		return fmt.Errorf("error parsing report template: %w", err)
+	}
+
+	optionsJSON, err := json.MarshalIndent(redactOptions(options), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling options: %w", err)
+	}
+
+	data := htmlReport{
+		Summary:     summary,
+		GeneratedAt: time.Now(),
+		Options:     string(optionsJSON),
+		Stages:      stageTimeline(options.Stages),
+		Trends: []htmlTrend{
+			{Name: "Iteration duration", Trend: summary.IterationDuration},
+			{Name: "HTTP request duration", Trend: summary.RequestDuration},
+			{Name: "Blocked", Trend: summary.Blocked},
+			{Name: "DNS lookup", Trend: summary.DNSLookup},
+			{Name: "TCP connect", Trend: summary.TCPConnect},
+			{Name: "TLS handshake", Trend: summary.TLSHandshake},
+			{Name: "Time to first byte", Trend: summary.TimeToFirstByte},
+		},
+		Charts: charts(summary.Timeline),
+	}
+
+	file, err := os.Create(h.path)
+	if err != nil {
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, data); err != nil {
+		return fmt.Errorf("error rendering report: %w", err)
+	}
+	return nil
+}
+
+// redacted replaces secrets in the options shown by the report
+const redacted = "[redacted]"
+
+// redactOptions returns a copy of the options without the certificate and key material or the output header values,
+// since the report is meant to be shared
+func redactOptions(options *models.Options) *models.Options {
+	copied := *options
+	if options.TLS != nil {
+		tlsOptions := *options.TLS
+		if tlsOptions.CACerts != "" {
+			tlsOptions.CACerts = redacted
+		}
+		tlsOptions.ClientCerts = make([]models.ClientCert, len(options.TLS.ClientCerts))
+		for i, cert := range options.TLS.ClientCerts {
+			tlsOptions.ClientCerts[i] = models.ClientCert{Domains: cert.Domains, Cert: redacted, Key: redacted}
+		}
+		copied.TLS = &tlsOptions
+	}
+	if len(options.Outputs.OTLP.Headers) > 0 {
+		copied.Outputs.OTLP.Headers = make(map[string]string, len(options.Outputs.OTLP.Headers))
+		for name := range options.Outputs.OTLP.Headers {
+			copied.Outputs.OTLP.Headers[name] = redacted
+		}
+	}
+	return &copied
+}
+
+// stageTimeline positions each stage relative to the total test duration
+func stageTimeline(stages []models.Stage) []htmlStage {
+	var total time.Duration
+	durations := make([]time.Duration, len(stages))
+	for i, stage := range stages {
+		duration, _, _, err := stage.GetDurations()
+		if err != nil {
+			continue
+		}
+		durations[i] = duration
+		total += duration
+	}
+	if total == 0 {
+		return nil
+	}
+
+	timeline := make([]htmlStage, 0, len(stages))
+	var offset time.Duration
+	for i, stage := range stages {
+		timeline = append(timeline, htmlStage{
+			Number:   i + 1,
+			Stage:    stage,
+			Offset:   float64(offset) / float64(total) * 100,
+			Width:    float64(durations[i]) / float64(total) * 100,
+			StartsAt: offset,
+		})
+		offset += durations[i]
+	}
+	return timeline
+}
+
+// charts builds the VU, RPS and latency charts from the timeline
+func charts(timeline []metrics.TimelinePoint) []htmlChart {
+	vus := make([]float64, len(timeline))
+	rps := make([]float64, len(timeline))
+	avg := make([]float64, len(timeline))
+	p95 := make([]float64, len(timeline))
+	for i, point := range timeline {
+		vus[i] = float64(point.VUs)
+		rps[i] = float64(point.Requests)
+		avg[i] = metrics.Milliseconds(point.AvgDuration)
+		p95[i] = metrics.Milliseconds(point.P95Duration)
+	}
+
+	return []htmlChart{
+		newChart("Virtual users", "VUs", map[string][]float64{"vus": vus}, []string{"vus"}),
+		newChart("Requests per second", "req/s", map[string][]float64{"rps": rps}, []string{"rps"}),
+		newChart("Request duration", "ms", map[string][]float64{"avg": avg, "p95": p95}, []string{"avg", "p95"}),
+	}
+}
+
+// seriesColors are the line colors used for the series of a chart, in order
+var seriesColors = []string{"#2563eb", "#dc2626", "#16a34a"}
+
+// newChart scales the series to the chart size and builds their SVG polyline points
+func newChart(
+	title, unit string,
+	values map[string][]float64,
+	order []string,
+) htmlChart {
+	chart := htmlChart{
+		Title:  title,
+		Unit:   unit,
+		Width:  chartWidth,
+		Height: chartHeight,
+	}
+
+	for _, name := range order {
+		for _, v := range values[name] {
+			if v > chart.Max {
+				chart.Max = v
+			}
+		}
+	}
+	scale := 1.0
+	if chart.Max > 0 {
+		scale = float64(chartHeight) / chart.Max
+	}
+
+	for i, name := range order {
+		series := values[name]
+		step := float64(chartWidth)
+		if len(series) > 1 {
+			step = float64(chartWidth) / float64(len(series)-1)
+		}
+		points := make([]string, len(series))
+		for j, v := range series {
+			points[j] = fmt.Sprintf("%.1f,%.1f", float64(j)*step, float64(chartHeight)-v*scale)
+		}
+		chart.Series = append(chart.Series, htmlSeries{
+			Name:   name,
+			Color:  seriesColors[i%len(seriesColors)],
+			Points: strings.Join(points, " "),
+		})
+	}
+	return chart
+}
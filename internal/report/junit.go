@@ -0,0 +1,111 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// JUnit writes thresholds and checks as JUnit XML test cases for CI systems
+type JUnit struct {
+	path string
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Write renders the report to the configured path
+func (j *JUnit) Write(
+	summary *metrics.Summary,
+	_ *models.Options,
+) error {
+	thresholds := junitTestSuite{Name: "thresholds"}
+	for _, result := range summary.ThresholdResults {
+		name := strings.TrimSpace(fmt.Sprintf("%s %s %v", result.Metric, result.Operator, result.Threshold))
+		testCase := junitTestCase{Name: name, Classname: "yalt.thresholds"}
+		if result.Error != "" {
+			testCase.Failure = &junitFailure{Message: result.Error, Type: "threshold", Text: result.Error}
+		} else if !result.Pass {
+			message := fmt.Sprintf("observed value %v does not satisfy %s %v", result.Value, result.Operator, result.Threshold)
+			testCase.Failure = &junitFailure{Message: message, Type: "threshold", Text: message}
+		}
+		thresholds.add(testCase)
+	}
+
+	checks := junitTestSuite{Name: "checks"}
+	for _, check := range summary.Checks {
+		testCase := junitTestCase{Name: check.Name, Classname: "yalt.checks"}
+		if check.Fails > 0 {
+			total := check.Passes + check.Fails
+			message := fmt.Sprintf("%d of %d evaluations failed (%.2f%% passed)",
+				check.Fails, total, float64(check.Passes)/float64(total)*100)
+			testCase.Failure = &junitFailure{Message: message, Type: "check", Text: message}
+		}
+		checks.add(testCase)
+	}
+
+	suites := junitTestSuites{Name: "yalt", Time: summary.Duration.Seconds()}
+	for _, suite := range []junitTestSuite{thresholds, checks} {
+		suite.Time = summary.Duration.Seconds()
+		suite.Timestamp = summary.StartTime.Format("2006-01-02T15:04:05")
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	file, err := os.Create(j.path)
+	if err != nil {
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(xml.Header); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("error rendering report: %w", err)
+	}
+	return nil
+}
+
+// add appends a test case and updates the suite counters
+func (s *junitTestSuite) add(testCase junitTestCase) {
+	s.Tests++
+	if testCase.Failure != nil {
+		s.Failures++
+	}
+	s.Cases = append(s.Cases, testCase)
+}
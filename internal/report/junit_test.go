@@ -0,0 +1,89 @@
+package report
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+)
+
+func TestJUnitWritesEveryThreshold(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	summary := &metrics.Summary{
+		StartTime: time.Now(),
+		Duration:  10 * time.Second,
+		ThresholdResults: []metrics.ThresholdResult{
+			{Metric: "http_req_duration p(95)", Operator: "<", Threshold: int64(200), Value: int64(120), Pass: true},
+			{Metric: "http_req_failed rate", Operator: "<", Threshold: 0.01, Value: 0.05},
+			{Metric: "http_req_waiting", Operator: "p(95) < 200", Threshold: "", Error: "unsupported threshold metric"},
+		},
+	}
+	if err := (&JUnit{path: path}).Write(summary, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 3 || suites.Failures != 2 {
+		t.Fatalf("got %d tests and %d failures, want 3 and 2", suites.Tests, suites.Failures)
+	}
+
+	cases := suites.Suites[0].Cases
+	if cases[0].Name != "http_req_duration p(95) < 200" || cases[0].Failure != nil {
+		t.Errorf("unexpected passing case %+v", cases[0])
+	}
+	if cases[1].Failure == nil || cases[1].Failure.Message != "observed value 0.05 does not satisfy < 0.01" {
+		t.Errorf("unexpected failing case %+v", cases[1])
+	}
+	if cases[2].Name != "http_req_waiting p(95) < 200" || cases[2].Failure == nil || cases[2].Failure.Message != "unsupported threshold metric" {
+		t.Errorf("unexpected invalid case %+v", cases[2])
+	}
+}
+
+func TestJUnitWritesEveryCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	summary := &metrics.Summary{
+		StartTime: time.Now(),
+		Duration:  10 * time.Second,
+		Checks: []metrics.CheckSummary{
+			{Name: "status is 200", Passes: 10},
+			{Name: "has items", Passes: 3, Fails: 1},
+		},
+	}
+	if err := (&JUnit{path: path}).Write(summary, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 2 || suites.Failures != 1 || len(suites.Suites) != 2 {
+		t.Fatalf("got %d tests, %d failures and %d suites, want 2, 1 and 2", suites.Tests, suites.Failures, len(suites.Suites))
+	}
+
+	checks := suites.Suites[1]
+	if checks.Name != "checks" || len(checks.Cases) != 2 {
+		t.Fatalf("unexpected checks suite %+v", checks)
+	}
+	if checks.Cases[0].Name != "status is 200" || checks.Cases[0].Classname != "yalt.checks" || checks.Cases[0].Failure != nil {
+		t.Errorf("unexpected passing case %+v", checks.Cases[0])
+	}
+	failure := checks.Cases[1].Failure
+	if failure == nil || failure.Message != "1 of 4 evaluations failed (75.00% passed)" || failure.Type != "check" {
+		t.Errorf("unexpected failing case %+v", checks.Cases[1])
+	}
+}
@@ -0,0 +1,30 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+)
+
+// Report writes the summary of a finished test run to a file
+type Report interface {
+	Write(summary *metrics.Summary, options *models.Options) error
+}
+
+// New creates a Report from a --report specification in the form format=path
+func New(spec string) (Report, error) {
+	format, path, _ := strings.Cut(spec, "=")
+	if path == "" {
+		return nil, fmt.Errorf("report %q requires a file path", format)
+	}
+	switch format {
+	case "html":
+		return &HTML{path: path}, nil
+	case "junit":
+		return &JUnit{path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+}
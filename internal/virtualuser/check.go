@@ -0,0 +1,43 @@
+package virtualuser
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+)
+
+// registerCheck registers the check function, which evaluates named predicates against a value and records each outcome.
+func registerCheck(
+	runtime *goja.Runtime,
+	m *metrics.Metrics,
+) error {
+	check := func(call goja.FunctionCall) goja.Value {
+		value := call.Argument(0)
+		if goja.IsUndefined(call.Argument(1)) || goja.IsNull(call.Argument(1)) {
+			panic(runtime.NewTypeError("check requires an object of named predicates"))
+		}
+		predicates := call.Argument(1).ToObject(runtime)
+
+		passed := true
+		for _, name := range predicates.Keys() {
+			predicate := predicates.Get(name)
+			pass := predicate.ToBoolean()
+			if fn, ok := goja.AssertFunction(predicate); ok {
+				result, err := fn(goja.Undefined(), value)
+				if err != nil {
+					panic(err)
+				}
+				pass = result.ToBoolean()
+			}
+			m.AddCheck(name, pass)
+			passed = passed && pass
+		}
+		return runtime.ToValue(passed)
+	}
+
+	if err := runtime.Set("check", check); err != nil {
+		return fmt.Errorf("failed to set check function: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package virtualuser
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/control"
+)
+
+// goError returns the Go error wrapped by a GoError value, or nil if the value is not one
+func goError(value goja.Value) error {
+	obj, ok := value.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	wrapped := obj.Get("value")
+	if wrapped == nil {
+		return nil
+	}
+	err, _ := wrapped.Export().(error)
+	return err
+}
+
+// isControlError reports whether err asks the engine to stop the virtual user or abort the test
+func isControlError(err error) bool {
+	return errors.Is(err, control.ErrStopVU) || errors.Is(err, control.ErrAbortTest)
+}
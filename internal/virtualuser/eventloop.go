@@ -0,0 +1,118 @@
+package virtualuser
+
+import (
+	"context"
+	"sync"
+)
+
+// eventLoop runs callbacks from asynchronous Go work on the goroutine that owns the VU's runtime.
+type eventLoop struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	queue   []func() error
+	pending int
+	wakeup  chan struct{}
+}
+
+// newEventLoop creates a new eventLoop.
+func newEventLoop() *eventLoop {
+	return &eventLoop{
+		ctx:    context.Background(),
+		wakeup: make(chan struct{}, 1),
+	}
+}
+
+// Context returns the context of the iteration currently running on the loop.
+func (l *eventLoop) Context() context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ctx
+}
+
+// RegisterCallback reserves a callback on the loop and returns the function used to schedule it.
+// The loop does not finish until every reserved callback has been scheduled, and the returned
+// function must be called exactly once, from any goroutine.
+func (l *eventLoop) RegisterCallback() func(func() error) {
+	l.mu.Lock()
+	l.pending++
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func(callback func() error) {
+		once.Do(func() {
+			l.mu.Lock()
+			l.queue = append(l.queue, callback)
+			l.pending--
+			l.mu.Unlock()
+
+			select {
+			case l.wakeup <- struct{}{}:
+			default:
+			}
+		})
+	}
+}
+
+// run calls fn and then runs scheduled callbacks until none are left or reserved.
+// When the context is cancelled the remaining callbacks are still awaited, but no longer run.
+func (l *eventLoop) run(
+	ctx context.Context,
+	fn func() error,
+) error {
+	l.mu.Lock()
+	l.ctx = ctx
+	l.mu.Unlock()
+
+	if err := fn(); err != nil {
+		l.drain()
+		return err
+	}
+
+	for {
+		l.mu.Lock()
+		queue := l.queue
+		l.queue = nil
+		pending := l.pending
+		l.mu.Unlock()
+
+		for _, callback := range queue {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := callback(); err != nil {
+				l.drain()
+				return err
+			}
+		}
+
+		if len(queue) == 0 && pending == 0 {
+			return nil
+		}
+		if ctx.Err() != nil {
+			l.drain()
+			return nil
+		}
+		if len(queue) == 0 {
+			select {
+			case <-l.wakeup:
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// drain waits for every reserved callback to be scheduled and discards them,
+// so nothing from a finished iteration runs during the next one.
+func (l *eventLoop) drain() {
+	for {
+		l.mu.Lock()
+		l.queue = nil
+		pending := l.pending
+		l.mu.Unlock()
+
+		if pending == 0 {
+			return
+		}
+		<-l.wakeup
+	}
+}
@@ -0,0 +1,210 @@
+package virtualuser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// registerLater registers later(value, fail) in the runtime, which returns a promise that settles
+// on the loop after a short delay, rejecting with the value when fail is true
+func registerLater(
+	t *testing.T,
+	runtime *goja.Runtime,
+	loop *eventLoop,
+) {
+	t.Helper()
+	err := runtime.Set("later", func(value goja.Value, fail bool) goja.Value {
+		promise, resolve, reject := runtime.NewPromise()
+		callback := loop.RegisterCallback()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			callback(func() error {
+				if fail {
+					reject(value)
+				} else {
+					resolve(value)
+				}
+				return nil
+			})
+		}()
+		return runtime.ToValue(promise)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventLoopSettlesPromises(t *testing.T) {
+	runtime := goja.New()
+	loop := newEventLoop()
+	registerLater(t, runtime, loop)
+
+	err := loop.run(context.Background(), func() error {
+		_, err := runtime.RunString(`
+			var results = [];
+			later("resolved", false).then(v => results.push(v));
+			later("rejected", true).catch(e => results.push("caught " + e));
+			(async () => {
+				const first = await later(1, false);
+				const second = await later(first + 1, false);
+				results.push("awaited " + second);
+			})();
+		`)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := runtime.Get("results").Export().([]interface{})
+	want := map[interface{}]bool{"resolved": true, "caught rejected": true, "awaited 2": true}
+	if len(results) != len(want) {
+		t.Fatalf("results = %v", results)
+	}
+	for _, result := range results {
+		if !want[result] {
+			t.Errorf("unexpected result %v in %v", result, results)
+		}
+	}
+}
+
+func TestEventLoopWaitsForPendingCallbacks(t *testing.T) {
+	loop := newEventLoop()
+	ran := false
+
+	start := time.Now()
+	err := loop.run(context.Background(), func() error {
+		callback := loop.RegisterCallback()
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			callback(func() error {
+				ran = true
+				return nil
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Error("run returned before the pending callback ran")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("run returned after %s", elapsed)
+	}
+}
+
+func TestEventLoopCancelSkipsCallbacks(t *testing.T) {
+	loop := newEventLoop()
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	scheduled := make(chan struct{})
+
+	err := loop.run(ctx, func() error {
+		callback := loop.RegisterCallback()
+		go func() {
+			cancel()
+			time.Sleep(20 * time.Millisecond)
+			callback(func() error {
+				ran = true
+				return nil
+			})
+			close(scheduled)
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-scheduled:
+	default:
+		t.Error("run returned before the reserved callback was scheduled")
+	}
+	if ran {
+		t.Error("callback ran after the context was cancelled")
+	}
+
+	// Nothing from the cancelled iteration is left for the next one
+	if err := loop.run(context.Background(), func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if ran {
+		t.Error("callback of the cancelled iteration ran in the next one")
+	}
+}
+
+func TestEventLoopStopsOnCallbackError(t *testing.T) {
+	loop := newEventLoop()
+	errStop := errors.New("stop")
+	ranAfterError := false
+
+	err := loop.run(context.Background(), func() error {
+		failing := loop.RegisterCallback()
+		later := loop.RegisterCallback()
+		failing(func() error { return errStop })
+		// Scheduling a callback twice has no effect
+		failing(func() error { return nil })
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			later(func() error {
+				ranAfterError = true
+				return nil
+			})
+		}()
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("run error = %v, want %v", err, errStop)
+	}
+	if ranAfterError {
+		t.Error("callback ran after an earlier callback failed")
+	}
+
+	// The loop is idle again, so the next iteration finishes immediately
+	done := make(chan error, 1)
+	go func() { done <- loop.run(context.Background(), func() error { return nil }) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("next iteration did not finish")
+	}
+}
+
+func TestEventLoopDrainsWhenScriptFails(t *testing.T) {
+	loop := newEventLoop()
+	errScript := errors.New("script failed")
+	ran := false
+
+	err := loop.run(context.Background(), func() error {
+		callback := loop.RegisterCallback()
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			callback(func() error {
+				ran = true
+				return nil
+			})
+		}()
+		return errScript
+	})
+	if !errors.Is(err, errScript) {
+		t.Fatalf("run error = %v, want %v", err, errScript)
+	}
+	if ran {
+		t.Error("callback ran after the script failed")
+	}
+	loop.mu.Lock()
+	pending := loop.pending
+	loop.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("%d callbacks still pending after run returned", pending)
+	}
+}
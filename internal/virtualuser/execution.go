@@ -0,0 +1,89 @@
+package virtualuser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultScenarioName is the name of the scenario every virtual user runs.
+const DefaultScenarioName = "default"
+
+// ExecutionState exposes the state of the running test to the virtual users.
+type ExecutionState interface {
+	// StageIndex returns the 0-based index of the stage currently running.
+	StageIndex() int
+	// ActiveVUs returns the number of virtual users currently active.
+	ActiveVUs() int64
+	// Elapsed returns the time since the test started.
+	Elapsed() time.Duration
+}
+
+// registerExecution exposes __VU, __ITER and the exec info object to the runtime.
+func registerExecution(
+	runtime *goja.Runtime,
+	vu *VirtualUser,
+) error {
+	if err := runtime.Set("__VU", vu.id); err != nil {
+		return fmt.Errorf("failed to set __VU: %w", err)
+	}
+	if err := runtime.Set("__ITER", vu.iteration); err != nil {
+		return fmt.Errorf("failed to set __ITER: %w", err)
+	}
+
+	getter := func(fn func() interface{}) goja.Value {
+		return runtime.ToValue(func(goja.FunctionCall) goja.Value {
+			return runtime.ToValue(fn())
+		})
+	}
+	define := func(obj *goja.Object, name string, fn func() interface{}) error {
+		return obj.DefineAccessorProperty(name, getter(fn), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+
+	vuInfo := runtime.NewObject()
+	if err := define(vuInfo, "id", func() interface{} { return vu.id }); err != nil {
+		return fmt.Errorf("failed to define exec.vu.id: %w", err)
+	}
+	if err := define(vuInfo, "iteration", func() interface{} { return vu.iteration }); err != nil {
+		return fmt.Errorf("failed to define exec.vu.iteration: %w", err)
+	}
+
+	stageInfo := runtime.NewObject()
+	if err := define(stageInfo, "index", func() interface{} { return vu.state.StageIndex() }); err != nil {
+		return fmt.Errorf("failed to define exec.stage.index: %w", err)
+	}
+
+	scenarioInfo := runtime.NewObject()
+	if err := scenarioInfo.Set("name", DefaultScenarioName); err != nil {
+		return fmt.Errorf("failed to set exec.scenario.name: %w", err)
+	}
+
+	testInfo := runtime.NewObject()
+	if err := define(testInfo, "elapsed", func() interface{} { return vu.state.Elapsed().Milliseconds() }); err != nil {
+		return fmt.Errorf("failed to define exec.test.elapsed: %w", err)
+	}
+	if err := define(testInfo, "vus", func() interface{} { return vu.state.ActiveVUs() }); err != nil {
+		return fmt.Errorf("failed to define exec.test.vus: %w", err)
+	}
+
+	exec := runtime.NewObject()
+	for _, field := range []struct {
+		name  string
+		value *goja.Object
+	}{
+		{"vu", vuInfo},
+		{"stage", stageInfo},
+		{"scenario", scenarioInfo},
+		{"test", testInfo},
+	} {
+		if err := exec.Set(field.name, field.value); err != nil {
+			return fmt.Errorf("failed to set exec.%s: %w", field.name, err)
+		}
+	}
+
+	if err := runtime.Set("exec", exec); err != nil {
+		return fmt.Errorf("failed to set exec object: %w", err)
+	}
+	return nil
+}
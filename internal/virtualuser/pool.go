@@ -1,46 +1,156 @@
 package virtualuser
 
 import (
-	"github.com/joakimcarlsson/yalt/internal/http"
+	"errors"
+	"fmt"
+	"runtime"
 	"sync"
 )
 
-// UserPool represents a pool of VirtualUsers.
+// errPoolFull is returned by create when every VirtualUser has already been initialized.
+var errPoolFull = errors.New("pool is full")
+
+// UserPool represents a pool of VirtualUsers that are initialized on demand.
 type UserPool struct {
-	pool *sync.Pool
+	config Config
+	size   int64
+	users  chan *VirtualUser
+
+	mu sync.Mutex
+	// created counts the VirtualUsers initialized or being initialized
+	created int64
+	// freeIDs holds the IDs of VirtualUsers that failed to initialize, reused before new ones
+	freeIDs []int64
 }
 
-// CreatePool creates a new UserPool.
+// CreatePool creates a new UserPool holding up to size VirtualUsers, numbered from 1.
+// No VirtualUser is initialized until Preallocate or Fetch is called.
 func CreatePool(
 	size int,
-	scriptContent []byte,
-	client *http.Client,
+	cfg Config,
 ) (*UserPool, error) {
-	pool := &sync.Pool{
-		New: func() interface{} {
-			vu, err := CreateVu(client, scriptContent)
-			if err != nil {
-				panic(err)
+	if size <= 0 {
+		return nil, fmt.Errorf("pool size must be greater than 0")
+	}
+
+	return &UserPool{
+		config: cfg,
+		size:   int64(size),
+		users:  make(chan *VirtualUser, size),
+	}, nil
+}
+
+// Preallocate initializes count VirtualUsers in parallel, calling progress after each one.
+func (p *UserPool) Preallocate(
+	count int,
+	progress func(initialized int),
+) error {
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		initialized int
+		firstErr    error
+	)
+
+	jobs := make(chan struct{})
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				vu, err := p.create()
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					p.users <- vu
+					initialized++
+					if progress != nil {
+						progress(initialized)
+					}
+				}
+				mu.Unlock()
 			}
-			return vu
-		},
+		}()
 	}
 
-	for i := 0; i < size; i++ {
-		pool.Put(pool.New())
+	for i := 0; i < count; i++ {
+		jobs <- struct{}{}
 	}
+	close(jobs)
+	wg.Wait()
 
-	return &UserPool{
-		pool: pool,
-	}, nil
+	return firstErr
 }
 
-// Fetch retrieves a VirtualUser from the pool.
-func (p *UserPool) Fetch() *VirtualUser {
-	return p.pool.Get().(*VirtualUser)
+// Fetch retrieves a VirtualUser from the pool, initializing a new one if none is idle and the pool is not full.
+func (p *UserPool) Fetch() (*VirtualUser, error) {
+	select {
+	case vu := <-p.users:
+		return vu, nil
+	default:
+	}
+
+	if !p.full() {
+		if vu, err := p.create(); !errors.Is(err, errPoolFull) {
+			return vu, err
+		}
+	}
+
+	return <-p.users, nil
 }
 
 // Return returns a VirtualUser to the pool.
 func (p *UserPool) Return(user *VirtualUser) {
-	p.pool.Put(user)
+	p.users <- user
+}
+
+// full reports whether every VirtualUser has been initialized or is being initialized
+func (p *UserPool) full() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.created >= p.size
+}
+
+// create initializes the next VirtualUser, releasing its ID again when the initialization fails
+func (p *UserPool) create() (*VirtualUser, error) {
+	id, err := p.reserveID()
+	if err != nil {
+		return nil, err
+	}
+
+	vu, err := CreateVu(id, p.config)
+	if err != nil {
+		p.releaseID(id)
+		return nil, fmt.Errorf("error creating virtual user %d: %w", id, err)
+	}
+	return vu, nil
+}
+
+// reserveID returns the ID of the next VirtualUser to initialize
+func (p *UserPool) reserveID() (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.created >= p.size {
+		return 0, errPoolFull
+	}
+	p.created++
+	if n := len(p.freeIDs); n > 0 {
+		id := p.freeIDs[n-1]
+		p.freeIDs = p.freeIDs[:n-1]
+		return id, nil
+	}
+	return p.created, nil
+}
+
+// releaseID gives back the ID of a VirtualUser that failed to initialize
+func (p *UserPool) releaseID(id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.created--
+	p.freeIDs = append(p.freeIDs, id)
 }
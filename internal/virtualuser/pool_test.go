@@ -0,0 +1,61 @@
+package virtualuser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joakimcarlsson/yalt/internal/http"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/models"
+	"github.com/joakimcarlsson/yalt/internal/modules"
+)
+
+// testConfig returns a Config running the script at scriptPath
+func testConfig(
+	t *testing.T,
+	scriptPath string,
+) Config {
+	t.Helper()
+	m := metrics.NewMetrics(nil)
+	client, err := http.NewClient(m, &models.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{
+		Client:     client,
+		Metrics:    m,
+		Loader:     modules.NewLoader(),
+		ScriptPath: scriptPath,
+	}
+}
+
+func TestPoolReleasesSlotWhenInitFails(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "script.js")
+	pool, err := CreatePool(1, testConfig(t, scriptPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The script does not exist yet, so the first virtual user fails to initialize
+	if _, err := pool.Fetch(); err == nil {
+		t.Fatal("expected the initialization to fail")
+	}
+	if pool.full() {
+		t.Fatal("failed initialization kept its slot in the pool")
+	}
+
+	if err := os.WriteFile(scriptPath, []byte("export function loadTest() {}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vu, err := pool.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vu.ID() != 1 {
+		t.Errorf("ID = %d, want 1", vu.ID())
+	}
+	if !pool.full() {
+		t.Error("pool should be full")
+	}
+}
@@ -0,0 +1,35 @@
+package virtualuser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// registerSleep registers the sleep function, which pauses the VU and returns early when the iteration is cancelled.
+func registerSleep(
+	runtime *goja.Runtime,
+	loop *eventLoop,
+) error {
+	sleep := func(call goja.FunctionCall) goja.Value {
+		seconds := call.Argument(0).ToFloat()
+		if seconds <= 0 {
+			return goja.Undefined()
+		}
+
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-loop.Context().Done():
+		}
+		return goja.Undefined()
+	}
+
+	if err := runtime.Set("sleep", sleep); err != nil {
+		return fmt.Errorf("failed to set sleep function: %w", err)
+	}
+	return nil
+}
@@ -2,62 +2,186 @@ package virtualuser
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/control"
+	"github.com/joakimcarlsson/yalt/internal/env"
 	"github.com/joakimcarlsson/yalt/internal/http"
+	"github.com/joakimcarlsson/yalt/internal/metrics"
+	"github.com/joakimcarlsson/yalt/internal/modules"
 	"log"
 )
 
+// Config holds the dependencies shared by every VirtualUser.
+type Config struct {
+	Client     *http.Client
+	Metrics    *metrics.Metrics
+	State      ExecutionState
+	Env        map[string]string
+	Loader     *modules.Loader
+	ScriptPath string
+	// NoCookiesReset keeps the cookies of a virtual user between iterations
+	NoCookiesReset bool
+	// NoVUConnectionReuse closes the connections of a virtual user after every iteration
+	NoVUConnectionReuse bool
+}
+
 // VirtualUser represents a virtual user.
 type VirtualUser struct {
+	id           int64
+	iteration    int64
+	runtime      *goja.Runtime
 	loadTestFunc goja.Callable
 	clientObject goja.Value
+	loop         *eventLoop
+	state        ExecutionState
+	cookieJar    *http.CookieJar
+	// resetCookies empties the cookie jar after every iteration
+	resetCookies bool
+	// client holds the transport of the virtual user
+	client *http.Client
+	// closeConnections closes the idle connections of the client after every iteration
+	closeConnections bool
+	// stopped is set once an iteration stops the virtual user, which then runs no more iterations
+	stopped bool
+}
+
+// ID returns the stable, 1-based identifier of the virtual user.
+func (vu *VirtualUser) ID() int64 {
+	return vu.id
+}
+
+// Stopped reports whether an iteration stopped the virtual user for the rest of the test.
+func (vu *VirtualUser) Stopped() bool {
+	return vu.stopped
 }
 
-// Run runs the virtual user for the specified duration, sending requests.
+// Run runs a single iteration of the load test function and waits for its Promise to settle.
+// An iteration failing with control.ErrStopVU stops the virtual user for the rest of the test.
 func (vu *VirtualUser) Run(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		return nil
 	default:
-		_, err := vu.loadTestFunc(goja.Undefined(), vu.clientObject)
-		if err != nil {
-			log.Printf("Error running load test function: %v", err)
-			return fmt.Errorf("error running load test function: %w", err)
+	}
+
+	if err := vu.runtime.Set("__ITER", vu.iteration); err != nil {
+		return fmt.Errorf("failed to set __ITER: %w", err)
+	}
+	defer func() {
+		vu.iteration++
+		if vu.resetCookies {
+			vu.cookieJar.Reset()
 		}
+		if vu.closeConnections {
+			vu.client.CloseIdleConnections()
+		}
+	}()
+
+	err := vu.iterate(ctx)
+	if errors.Is(err, control.ErrStopVU) {
+		vu.stopped = true
+	}
+	return err
+}
+
+// iterate calls the load test function and waits for its Promise to settle
+func (vu *VirtualUser) iterate(ctx context.Context) error {
+	var result goja.Value
+	err := vu.loop.run(ctx, func() error {
+		value, err := vu.loadTestFunc(goja.Undefined(), vu.clientObject)
+		result = value
+		return err
+	})
+	if err != nil {
+		if isControlError(err) {
+			return err
+		}
+		log.Printf("Error running load test function: %v", err)
+		return fmt.Errorf("error running load test function: %w", err)
+	}
+
+	promise, ok := result.Export().(*goja.Promise)
+	if !ok {
 		return nil
 	}
+	switch promise.State() {
+	case goja.PromiseStateRejected:
+		if cause := goError(promise.Result()); isControlError(cause) {
+			return cause
+		}
+		reason := rejectionReason(promise.Result())
+		log.Printf("Load test function rejected: %s", reason)
+		return fmt.Errorf("load test function rejected: %s", reason)
+	case goja.PromiseStatePending:
+		if ctx.Err() == nil {
+			return fmt.Errorf("load test function never settled its promise")
+		}
+	}
+	return nil
 }
 
 // CreateVu creates a new VirtualUser.
 func CreateVu(
-	client *http.Client,
-	scriptContent []byte,
+	id int64,
+	cfg Config,
 ) (*VirtualUser, error) {
-	runtime, err := setupRuntime(client)
+	vu := &VirtualUser{
+		id:               id,
+		loop:             newEventLoop(),
+		state:            cfg.State,
+		cookieJar:        http.NewCookieJar(),
+		resetCookies:     !cfg.NoCookiesReset,
+		closeConnections: cfg.NoVUConnectionReuse,
+	}
+
+	client, err := cfg.Client.ForVirtualUser(vu.cookieJar)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	vu.client = client
+
+	runtime, err := setupRuntime(client, cfg.Metrics, vu.loop, vu.cookieJar, cfg.Env)
 	if err != nil {
 		return nil, fmt.Errorf("failed to set up runtime: %w", err)
 	}
+	vu.runtime = runtime
+
+	if err := registerExecution(runtime, vu); err != nil {
+		return nil, err
+	}
+
+	if err := modules.RegisterSharedArray(runtime, cfg.Loader); err != nil {
+		return nil, err
+	}
 
-	if _, err := runtime.RunString(string(scriptContent)); err != nil {
+	registry := modules.NewRegistry(runtime, cfg.Loader)
+	exports, err := registry.Require(cfg.ScriptPath)
+	if err != nil {
 		return nil, fmt.Errorf("failed to run script: %w", err)
 	}
+	registry.EndInit()
 
-	loadTestFunc, err := getLoadTestFunc(runtime)
+	loadTestFunc, err := getLoadTestFunc(runtime, exports)
 	if err != nil {
 		return nil, fmt.Errorf("error getting load test function: %w", err)
 	}
 
-	clientObject := runtime.GlobalObject().Get("client")
+	vu.loadTestFunc = loadTestFunc
+	vu.clientObject = runtime.GlobalObject().Get("client")
 
-	return &VirtualUser{
-		loadTestFunc: loadTestFunc,
-		clientObject: clientObject,
-	}, nil
+	return vu, nil
 }
 
 // setupRuntime initializes the JavaScript runtime and registers necessary objects and methods.
-func setupRuntime(client *http.Client) (*goja.Runtime, error) {
+func setupRuntime(
+	client *http.Client,
+	m *metrics.Metrics,
+	loop *eventLoop,
+	jar *http.CookieJar,
+	environment map[string]string,
+) (*goja.Runtime, error) {
 	runtime := goja.New()
 
 	logHandler := func(call goja.FunctionCall) goja.Value {
@@ -77,21 +201,40 @@ func setupRuntime(client *http.Client) (*goja.Runtime, error) {
 		return nil, fmt.Errorf("failed to set console object: %w", err)
 	}
 
-	exports := runtime.NewObject()
-	if err := runtime.Set("exports", exports); err != nil {
-		return nil, fmt.Errorf("failed to set exports object: %w", err)
+	if err := env.Register(runtime, environment); err != nil {
+		return nil, fmt.Errorf("failed to register environment variables: %w", err)
 	}
 
-	if err := http.RegisterClientMethods(runtime, client); err != nil {
+	if err := http.RegisterClientMethods(runtime, client, loop, jar); err != nil {
 		return nil, fmt.Errorf("failed to register client methods: %w", err)
 	}
 
+	if err := registerCheck(runtime, m); err != nil {
+		return nil, err
+	}
+
+	if err := registerSleep(runtime, loop); err != nil {
+		return nil, err
+	}
+
 	return runtime, nil
 }
 
+// rejectionReason describes a rejection value, preferring the stack trace of Error objects.
+func rejectionReason(reason goja.Value) string {
+	if obj, ok := reason.(*goja.Object); ok {
+		if stack := obj.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+			return stack.String()
+		}
+	}
+	return reason.String()
+}
+
 // getLoadTestFunc retrieves the loadTest function from the exports object.
-func getLoadTestFunc(runtime *goja.Runtime) (goja.Callable, error) {
-	exports := runtime.Get("exports")
+func getLoadTestFunc(
+	runtime *goja.Runtime,
+	exports goja.Value,
+) (goja.Callable, error) {
 	loadTestFunc, ok := goja.AssertFunction(exports.ToObject(runtime).Get("loadTest"))
 	if !ok {
 		return nil, fmt.Errorf("loadTest function not found in exports")
@@ -0,0 +1,67 @@
+package virtualuser
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/joakimcarlsson/yalt/internal/control"
+)
+
+func TestStopVUStopsForTheRestOfTheTest(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "script.js")
+	script := "export function loadTest() { if (__ITER === 1) { stop(); } }"
+	if err := os.WriteFile(scriptPath, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vu, err := CreateVu(1, testConfig(t, scriptPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vu.runtime.Set("stop", func(goja.FunctionCall) goja.Value {
+		panic(vu.runtime.NewGoError(control.ErrStopVU))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vu.Run(context.Background()); err != nil || vu.Stopped() {
+		t.Fatalf("first iteration: err = %v, stopped = %v", err, vu.Stopped())
+	}
+	if err := vu.Run(context.Background()); !errors.Is(err, control.ErrStopVU) {
+		t.Fatalf("second iteration: err = %v, want ErrStopVU", err)
+	}
+	if !vu.Stopped() {
+		t.Error("virtual user should stay stopped")
+	}
+}
+
+func TestCheckRecordsEveryPredicate(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "script.js")
+	script := `export function loadTest() {
+		if (check({ status: 500 }, { "status is 200": (r) => r.status === 200, "has status": (r) => r.status > 0 })) {
+			throw new Error("check passed although a predicate failed");
+		}
+	}`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t, scriptPath)
+	vu, err := CreateVu(1, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vu.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := cfg.Metrics.Summary().Checks
+	if len(checks) != 2 {
+		t.Fatalf("got %d checks, want 2", len(checks))
+	}
+	if checks[0].Name != "status is 200" || checks[0].Fails != 1 || checks[1].Name != "has status" || checks[1].Passes != 1 {
+		t.Errorf("unexpected checks %+v", checks)
+	}
+}